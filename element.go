@@ -26,6 +26,9 @@ type Metadata struct {
 	Version   int32
 	Timestamp time.Time
 	Changeset int64
+	// Visible is false for deleted elements. Only files with historical
+	// information or diffs can contain deleted elements.
+	Visible bool
 }
 
 // A Node contains lat/long coordinates.
@@ -1,9 +1,11 @@
 module github.com/omniscale/go-osm
 
-go 1.21.0
+go 1.22
 
 require (
 	github.com/gogo/protobuf v1.3.2
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.9
 	gopkg.in/fsnotify.v1 v1.4.7
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -1,4 +1,6 @@
 /*
-Package changeset provides a parser for OSM changeset files.
+Package changeset provides a parser and a writer for OSM changeset files,
+like the changeset replication files or the full changesets-latest.osm.bz2
+dump.
 */
 package changeset
@@ -4,12 +4,6 @@ import (
 	"time"
 )
 
-type ChangeFile struct {
-	XMLName   string      `xml:"osm"`
-	Generator string      `xml:"generator,attr"`
-	Changes   []Changeset `xml:"changeset"`
-}
-
 type Changeset struct {
 	ID         int64     `xml:"id,attr"`
 	CreatedAt  time.Time `xml:"created_at,attr"`
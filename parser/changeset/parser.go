@@ -1,6 +1,7 @@
 package changeset
 
 import (
+	"compress/bzip2"
 	"compress/gzip"
 	"context"
 	"encoding/xml"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/omniscale/go-osm"
 	"github.com/omniscale/go-osm/parser/changeset/internal/osmxml"
+	"github.com/omniscale/go-osm/parser/internal/stream"
 )
 
 type Parser struct {
@@ -40,11 +42,21 @@ func NewGZIP(r io.Reader, conf Config) (*Parser, error) {
 	return New(r, conf), nil
 }
 
+// NewBZIP2 returns a parser from a BZIP2 compressed io.Reader, e.g. for the
+// changesets-latest.osm.bz2 dump of planet.openstreetmap.org.
+func NewBZIP2(r io.Reader, conf Config) (*Parser, error) {
+	return New(bzip2.NewReader(r), conf), nil
+}
+
 // Error returns the first error that occurred during Header/Parse calls.
 func (p *Parser) Error() error {
 	return p.err
 }
 
+// Parse parses the changeset file and sends the parsed changesets into the
+// Changesets channel provided to the Parsers Config. Each changeset is sent
+// as soon as it is parsed, the whole file is never loaded into memory. Context can be used to
+// cancel the parsing; Parse returns ctx.Err() in this case.
 func (p *Parser) Parse(ctx context.Context) (err error) {
 	if p.err != nil {
 		return p.err
@@ -64,12 +76,25 @@ func (p *Parser) Parse(ctx context.Context) (err error) {
 	}
 
 	dec := xml.NewDecoder(p.reader)
-	cf := osmxml.ChangeFile{}
-	if err := dec.Decode(&cf); err != nil {
-		return fmt.Errorf("decoding changes file: %w", err)
-	}
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("decoding next XML token: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "changeset" {
+			continue
+		}
 
-	for _, ch := range cf.Changes {
+		// decode single changeset to keep memory usage constant for large
+		// changeset dumps
+		ch := osmxml.Changeset{}
+		if err := dec.DecodeElement(&ch, &start); err != nil {
+			return fmt.Errorf("decoding changeset: %w", err)
+		}
 		result := osm.Changeset{
 			ID:         ch.ID,
 			CreatedAt:  ch.CreatedAt,
@@ -103,11 +128,8 @@ func (p *Parser) Parse(ctx context.Context) (err error) {
 		}
 		result.Comments = comment
 
-		select {
-		case <-ctx.Done():
-		case p.conf.Changesets <- result:
+		if err := stream.Send(ctx, p.conf.Changesets, result); err != nil {
+			return err
 		}
 	}
-
-	return nil
 }
@@ -2,8 +2,10 @@ package changeset
 
 import (
 	"context"
+	"io"
 	"os"
 	"testing"
+	"time"
 
 	osm "github.com/omniscale/go-osm"
 )
@@ -45,3 +47,105 @@ func TestParse(t *testing.T) {
 	}
 
 }
+
+func TestParseCancel(t *testing.T) {
+	conf := Config{
+		Changesets: make(chan osm.Changeset),
+	}
+	f, err := os.Open("999.osm.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p, err := NewGZIP(f, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		// stop reading after the first changeset
+		<-conf.Changesets
+		stop()
+	}()
+
+	if err := p.Parse(ctx); err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if _, ok := <-conf.Changesets; ok {
+		t.Error("Changesets not closed after cancel")
+	}
+	if err := p.Error(); err != context.Canceled {
+		t.Error("expected context.Canceled from Error, got", err)
+	}
+}
+
+func TestParseBZIP2(t *testing.T) {
+	conf := Config{
+		Changesets: make(chan osm.Changeset),
+	}
+	f, err := os.Open("999.osm.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p, err := NewBZIP2(f, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go p.Parse(context.Background())
+
+	changes := []osm.Changeset{}
+	for ch := range conf.Changesets {
+		changes = append(changes, ch)
+	}
+	if err := p.Error(); err != nil {
+		t.Error(err)
+	}
+	if n := len(changes); n != 27 {
+		t.Error("expected 27 changes, got", n)
+	}
+}
+
+func TestParseStreaming(t *testing.T) {
+	conf := Config{
+		Changesets: make(chan osm.Changeset),
+	}
+	r, w := io.Pipe()
+	p := New(r, conf)
+	go p.Parse(context.Background())
+
+	// first changeset is sent before the rest of the file is available
+	go io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="test">
+ <changeset id="1" created_at="2016-11-01T12:00:00Z" open="true" user="foo" uid="42" num_changes="3">
+  <tag k="comment" v="first"/>
+ </changeset>
+ <changeset id="2" `)
+
+	select {
+	case ch := <-conf.Changesets:
+		if ch.ID != 1 || ch.Tags["comment"] != "first" || !ch.Open || ch.UserID != 42 {
+			t.Errorf("unexpected changeset %#v", ch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("first changeset not sent before end of input")
+	}
+
+	go func() {
+		io.WriteString(w, `created_at="2016-11-01T12:00:00Z" open="false" user="bar" uid="43" num_changes="1"/>
+</osm>`)
+		w.Close()
+	}()
+	if ch := <-conf.Changesets; ch.ID != 2 || ch.Open {
+		t.Errorf("unexpected changeset %#v", ch)
+	}
+	if _, ok := <-conf.Changesets; ok {
+		t.Error("Changesets not closed")
+	}
+	if err := p.Error(); err != nil {
+		t.Error(err)
+	}
+}
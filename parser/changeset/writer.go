@@ -0,0 +1,169 @@
+package changeset
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+	"github.com/omniscale/go-osm/parser/internal/xmlelem"
+)
+
+type WriterConfig struct {
+	// Generator is written as generator attribute of the osm element.
+	// Defaults to github.com/omniscale/go-osm.
+	Generator string
+}
+
+// Writer encodes changesets into an OSM changeset file, as used by the
+// changeset replication of planet.openstreetmap.org.
+type Writer struct {
+	w             io.Writer
+	gz            *gzip.Writer
+	conf          WriterConfig
+	headerWritten bool
+	buf           []byte
+	err           error
+}
+
+// NewWriter creates a new changeset writer for the provided output. Close
+// needs to be called after all changesets are written.
+func NewWriter(w io.Writer, conf WriterConfig) *Writer {
+	return &Writer{w: w, conf: conf}
+}
+
+// NewGZIPWriter creates a new changeset writer for GZIP compressed output
+// (.osm.gz), as used by replication servers. Close flushes the GZIP stream.
+func NewGZIPWriter(w io.Writer, conf WriterConfig) *Writer {
+	gz := gzip.NewWriter(w)
+	return &Writer{w: gz, gz: gz, conf: conf}
+}
+
+// Write writes a single changeset. The closed_at attribute is only written
+// for closed changesets, and the bbox only if MaxExtent is not zero.
+func (w *Writer) Write(ch osm.Changeset) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.writeHeader()
+	w.buf = appendChangeset(w.buf, &ch)
+	return w.flush()
+}
+
+// Close writes the end of the document. It does not close the underlying
+// io.Writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.writeHeader()
+	w.buf = append(w.buf, "</osm>\n"...)
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			w.err = fmt.Errorf("closing gzip writer: %w", err)
+			return w.err
+		}
+	}
+	w.err = stream.ErrWriterClosed
+	return nil
+}
+
+func (w *Writer) writeHeader() {
+	if w.headerWritten {
+		return
+	}
+	w.headerWritten = true
+	generator := w.conf.Generator
+	if generator == "" {
+		generator = stream.DefaultGenerator
+	}
+	w.buf = append(w.buf, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"...)
+	w.buf = append(w.buf, `<osm version="0.6" generator="`...)
+	w.buf = xmlelem.AppendEscaped(w.buf, generator)
+	w.buf = append(w.buf, "\">\n"...)
+}
+
+func (w *Writer) flush() error {
+	if _, err := w.w.Write(w.buf); err != nil {
+		w.err = fmt.Errorf("writing changesets: %w", err)
+		return w.err
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func appendChangeset(buf []byte, ch *osm.Changeset) []byte {
+	buf = append(buf, `  <changeset id="`...)
+	buf = strconv.AppendInt(buf, ch.ID, 10)
+	buf = append(buf, `" created_at="`...)
+	buf = ch.CreatedAt.UTC().AppendFormat(buf, time.RFC3339)
+	if !ch.Open {
+		buf = append(buf, `" closed_at="`...)
+		buf = ch.ClosedAt.UTC().AppendFormat(buf, time.RFC3339)
+	}
+	buf = append(buf, `" open="`...)
+	buf = strconv.AppendBool(buf, ch.Open)
+	buf = append(buf, `" num_changes="`...)
+	buf = strconv.AppendInt(buf, int64(ch.NumChanges), 10)
+	buf = append(buf, `" user="`...)
+	buf = xmlelem.AppendEscaped(buf, ch.UserName)
+	buf = append(buf, `" uid="`...)
+	buf = strconv.AppendInt(buf, int64(ch.UserID), 10)
+	buf = append(buf, '"')
+	if ch.MaxExtent != [4]float64{} {
+		buf = append(buf, ` min_lat="`...)
+		buf = strconv.AppendFloat(buf, ch.MaxExtent[1], 'f', -1, 64)
+		buf = append(buf, `" max_lat="`...)
+		buf = strconv.AppendFloat(buf, ch.MaxExtent[3], 'f', -1, 64)
+		buf = append(buf, `" min_lon="`...)
+		buf = strconv.AppendFloat(buf, ch.MaxExtent[0], 'f', -1, 64)
+		buf = append(buf, `" max_lon="`...)
+		buf = strconv.AppendFloat(buf, ch.MaxExtent[2], 'f', -1, 64)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, ` comments_count="`...)
+	buf = strconv.AppendInt(buf, int64(len(ch.Comments)), 10)
+	buf = append(buf, '"')
+
+	if len(ch.Tags) == 0 && len(ch.Comments) == 0 {
+		return append(buf, "/>\n"...)
+	}
+	buf = append(buf, ">\n"...)
+
+	keys := make([]string, 0, len(ch.Tags))
+	for k := range ch.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf = append(buf, `    <tag k="`...)
+		buf = xmlelem.AppendEscaped(buf, k)
+		buf = append(buf, `" v="`...)
+		buf = xmlelem.AppendEscaped(buf, ch.Tags[k])
+		buf = append(buf, "\"/>\n"...)
+	}
+
+	if len(ch.Comments) > 0 {
+		buf = append(buf, "    <discussion>\n"...)
+		for _, c := range ch.Comments {
+			buf = append(buf, `      <comment uid="`...)
+			buf = strconv.AppendInt(buf, int64(c.UserID), 10)
+			buf = append(buf, `" user="`...)
+			buf = xmlelem.AppendEscaped(buf, c.UserName)
+			buf = append(buf, `" date="`...)
+			buf = c.CreatedAt.UTC().AppendFormat(buf, time.RFC3339)
+			buf = append(buf, "\">\n        <text>"...)
+			buf = xmlelem.AppendEscapedText(buf, c.Text)
+			buf = append(buf, "</text>\n      </comment>\n"...)
+		}
+		buf = append(buf, "    </discussion>\n"...)
+	}
+	return append(buf, "  </changeset>\n"...)
+}
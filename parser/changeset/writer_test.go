@@ -0,0 +1,103 @@
+package changeset
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+)
+
+func parseChangesets(t *testing.T, r io.Reader) []osm.Changeset {
+	t.Helper()
+	conf := Config{
+		Changesets: make(chan osm.Changeset),
+	}
+	p, err := NewGZIP(r, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go p.Parse(context.Background())
+
+	changes := []osm.Changeset{}
+	for ch := range conf.Changesets {
+		changes = append(changes, ch)
+	}
+	if err := p.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return changes
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	f, err := os.Open("999.osm.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parseChangesets(t, f)
+	f.Close()
+
+	buf := &bytes.Buffer{}
+	w := NewGZIPWriter(buf, WriterConfig{})
+	for _, ch := range want {
+		if err := w.Write(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := parseChangesets(t, buf)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changesets differ after round trip\n%#v\n%#v", got, want)
+	}
+}
+
+func TestWriterOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{Generator: "test"})
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, ch := range []osm.Changeset{
+		{
+			ID: 1, CreatedAt: ts, ClosedAt: ts.Add(time.Minute), NumChanges: 2, UserID: 42, UserName: "foo",
+			MaxExtent: [4]float64{7.4, 43.7, 7.5, 43.8},
+			Tags:      osm.Tags{"comment": "a & b"},
+			Comments:  []osm.Comment{{UserID: 43, UserName: "bar", CreatedAt: ts.Add(time.Hour), Text: "<hello>\nworld"}},
+		},
+		{ID: 2, CreatedAt: ts, Open: true, UserID: 42, UserName: "foo"},
+	} {
+		if err := w.Write(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="test">
+  <changeset id="1" created_at="2020-01-02T03:04:05Z" closed_at="2020-01-02T03:05:05Z" open="false" num_changes="2" user="foo" uid="42" min_lat="43.7" max_lat="43.8" min_lon="7.4" max_lon="7.5" comments_count="1">
+    <tag k="comment" v="a &amp; b"/>
+    <discussion>
+      <comment uid="43" user="bar" date="2020-01-02T04:04:05Z">
+        <text>&lt;hello&gt;
+world</text>
+      </comment>
+    </discussion>
+  </changeset>
+  <changeset id="2" created_at="2020-01-02T03:04:05Z" open="true" num_changes="0" user="foo" uid="42" comments_count="0"/>
+</osm>
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output\n%s\nwant\n%s", buf.String(), want)
+	}
+	if err := w.Write(osm.Changeset{}); err != stream.ErrWriterClosed {
+		t.Error("expected error after Close, got", err)
+	}
+}
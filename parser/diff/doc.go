@@ -1,4 +1,4 @@
 /*
-Package diff provides a parser for OSM diff files (.osc).
+Package diff provides a parser and a writer for OSM diff files (.osc).
 */
 package diff
@@ -6,10 +6,10 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
-	"strconv"
-	"time"
 
 	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+	"github.com/omniscale/go-osm/parser/internal/xmlelem"
 )
 
 // Parser is a stream based parser for OSM diff files (.osc).
@@ -30,6 +30,13 @@ type Config struct {
 	// KeepOpen specifies whether the destination channel should be keept open
 	// after Parse(). By default, the Elements channel is closed after Parse().
 	KeepOpen bool
+
+	// Strict specifies whether Parse should return an error for invalid
+	// numbers, timestamps and member types, for unknown actions and for
+	// elements outside of a create, modify or delete block. The error
+	// contains the line and offset where the invalid element ends. By default,
+	// invalid values are set to zero and invalid members are ignored.
+	Strict bool
 }
 
 // New creates a new parser for the provided input. Config specifies the destinations for the parsed elements.
@@ -51,9 +58,12 @@ func (p *Parser) Error() error {
 	return p.err
 }
 
+// Parse parses the diff file and sends the parsed elements into the Diffs
+// channel provided to the Parsers Config. Context can be used to cancel the
+// parsing; Parse returns ctx.Err() in this case.
 func (p *Parser) Parse(ctx context.Context) (err error) {
 	if p.err != nil {
-		return err
+		return p.err
 	}
 
 	defer func() {
@@ -70,26 +80,34 @@ func (p *Parser) Parse(ctx context.Context) (err error) {
 		}()
 	}
 	decoder := xml.NewDecoder(p.reader)
+	builder := xmlelem.Builder{IncludeMetadata: p.conf.IncludeMetadata, Strict: p.conf.Strict}
+
+	// posErr adds the current position of the decoder to err
+	posErr := func(err error) error {
+		line, col := decoder.InputPos()
+		return fmt.Errorf("line %d, column %d (offset %d): %w", line, col, decoder.InputOffset(), err)
+	}
 
 	add := false
 	mod := false
 	del := false
-	tags := make(map[string]string)
-	newElem := false
+	depth := 0
 
-	node := &osm.Node{}
-	way := &osm.Way{}
-	rel := &osm.Relation{}
-
-NextToken:
 	for {
 		token, err := decoder.Token()
 		if err != nil {
+			if p.conf.Strict {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				err = posErr(err)
+			}
 			return fmt.Errorf("decoding next XML token: %w", err)
 		}
 
 		switch tok := token.(type) {
 		case xml.StartElement:
+			depth++
 			switch tok.Name.Local {
 			case "create":
 				add = true
@@ -103,154 +121,47 @@ NextToken:
 				add = false
 				mod = false
 				del = true
-			case "node":
-				for _, attr := range tok.Attr {
-					switch attr.Name.Local {
-					case "id":
-						node.ID, _ = strconv.ParseInt(attr.Value, 10, 64)
-					case "lat":
-						node.Lat, _ = strconv.ParseFloat(attr.Value, 64)
-					case "lon":
-						node.Long, _ = strconv.ParseFloat(attr.Value, 64)
-					}
-				}
-				if p.conf.IncludeMetadata {
-					setElemMetadata(tok.Attr, &node.Element)
-				}
-			case "way":
-				for _, attr := range tok.Attr {
-					if attr.Name.Local == "id" {
-						way.ID, _ = strconv.ParseInt(attr.Value, 10, 64)
-					}
-				}
-				if p.conf.IncludeMetadata {
-					setElemMetadata(tok.Attr, &way.Element)
-				}
-			case "relation":
-				for _, attr := range tok.Attr {
-					if attr.Name.Local == "id" {
-						rel.ID, _ = strconv.ParseInt(attr.Value, 10, 64)
-					}
-				}
-				if p.conf.IncludeMetadata {
-					setElemMetadata(tok.Attr, &rel.Element)
-				}
-			case "nd":
-				for _, attr := range tok.Attr {
-					if attr.Name.Local == "ref" {
-						ref, _ := strconv.ParseInt(attr.Value, 10, 64)
-						way.Refs = append(way.Refs, ref)
+			default:
+				if p.conf.Strict {
+					if depth == 1 && tok.Name.Local != "osmChange" {
+						return posErr(fmt.Errorf("invalid root element %s, expected osmChange", tok.Name.Local))
 					}
-				}
-			case "member":
-				member := osm.Member{}
-				for _, attr := range tok.Attr {
-					switch attr.Name.Local {
-					case "type":
-						var ok bool
-						member.Type, ok = memberTypeValues[attr.Value]
-						if !ok {
-							// ignore unknown member types
-							continue NextToken
-						}
-					case "role":
-						member.Role = attr.Value
-					case "ref":
-						var err error
-						member.ID, err = strconv.ParseInt(attr.Value, 10, 64)
-						if err != nil {
-							// ignore invalid ref
-							continue NextToken
+					if depth == 2 {
+						switch tok.Name.Local {
+						case "node", "way", "relation":
+							return posErr(fmt.Errorf("%s outside of create, modify or delete", tok.Name.Local))
+						default:
+							return posErr(fmt.Errorf("unknown action %s", tok.Name.Local))
 						}
 					}
 				}
-				rel.Members = append(rel.Members, member)
-			case "tag":
-				var k, v string
-				for _, attr := range tok.Attr {
-					if attr.Name.Local == "k" {
-						k = attr.Value
-					} else if attr.Name.Local == "v" {
-						v = attr.Value
-					}
+				// node, way, relation and their children, other tags are
+				// ignored
+				if err := builder.Start(tok); err != nil {
+					return posErr(fmt.Errorf("invalid %s: %w", tok.Name.Local, err))
 				}
-				tags[k] = v
-			case "osmChange":
-				// pass
-			default:
-				// unhandled XML tag, pass
 			}
 		case xml.EndElement:
-			var e osm.Diff
-			switch tok.Name.Local {
-			case "node":
-				if len(tags) > 0 {
-					node.Tags = tags
-				}
-				e.Node = node
-				node = &osm.Node{}
-				newElem = true
-			case "way":
-				if len(tags) > 0 {
-					way.Tags = tags
-				}
-				e.Way = way
-				way = &osm.Way{}
-				newElem = true
-			case "relation":
-				if len(tags) > 0 {
-					rel.Tags = tags
-				}
-				e.Rel = rel
-				rel = &osm.Relation{}
-				newElem = true
-			case "osmChange":
+			depth--
+			if tok.Name.Local == "osmChange" {
 				// EOF
 				return nil
 			}
-
-			if newElem {
-				e.Create = add
-				e.Delete = del
-				e.Modify = mod
-				if len(tags) > 0 {
-					tags = make(map[string]string)
-				}
-				newElem = false
-				select {
-				case <-ctx.Done():
-				case p.conf.Diffs <- e:
-				}
+			node, way, rel := builder.End(tok)
+			if node == nil && way == nil && rel == nil {
+				continue
+			}
+			e := osm.Diff{
+				Create: add,
+				Modify: mod,
+				Delete: del,
+				Node:   node,
+				Way:    way,
+				Rel:    rel,
+			}
+			if err := stream.Send(ctx, p.conf.Diffs, e); err != nil {
+				return err
 			}
 		}
 	}
-
-	return nil
-}
-
-func setElemMetadata(attrs []xml.Attr, elem *osm.Element) {
-	elem.Metadata = &osm.Metadata{}
-	for _, attr := range attrs {
-		switch attr.Name.Local {
-		case "version":
-			v, _ := strconv.ParseInt(attr.Value, 10, 64)
-			elem.Metadata.Version = int32(v)
-		case "uid":
-			v, _ := strconv.ParseInt(attr.Value, 10, 64)
-			elem.Metadata.UserID = int32(v)
-		case "user":
-			elem.Metadata.UserName = attr.Value
-		case "changeset":
-			v, _ := strconv.ParseInt(attr.Value, 10, 64)
-			elem.Metadata.Changeset = v
-		case "timestamp":
-			elem.Metadata.Timestamp, _ = time.Parse(time.RFC3339, attr.Value)
-		}
-	}
-}
-
-var memberTypeValues = map[string]osm.MemberType{
-	"node":     osm.NodeMember,
-	"way":      osm.WayMember,
-	"relation": osm.RelationMember,
 }
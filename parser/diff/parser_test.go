@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -60,7 +61,7 @@ func TestParse(t *testing.T) {
 					Element: osm.Element{
 						ID:       25594547,
 						Tags:     osm.Tags{"source": "SRTM"},
-						Metadata: &osm.Metadata{UserID: 462835, UserName: "G-eMapper", Version: 3, Timestamp: time.Date(2016, 12, 2, 14, 15, 11, 0, time.UTC), Changeset: 44115151},
+						Metadata: &osm.Metadata{UserID: 462835, UserName: "G-eMapper", Version: 3, Timestamp: time.Date(2016, 12, 2, 14, 15, 11, 0, time.UTC), Changeset: 44115151, Visible: true},
 					},
 					Lat:  16.187913,
 					Long: 122.0913159,
@@ -77,7 +78,7 @@ func TestParse(t *testing.T) {
 				Node: &osm.Node{
 					Element: osm.Element{
 						ID:       1884933281,
-						Metadata: &osm.Metadata{UserID: 3315483, UserName: "8dirfriend", Version: 2, Timestamp: time.Date(2016, 12, 2, 14, 15, 10, 0, time.UTC), Changeset: 44115150},
+						Metadata: &osm.Metadata{UserID: 3315483, UserName: "8dirfriend", Version: 2, Timestamp: time.Date(2016, 12, 2, 14, 15, 10, 0, time.UTC), Changeset: 44115150, Visible: true},
 					},
 					Lat:  35.0233546,
 					Long: 132.879755,
@@ -95,7 +96,7 @@ func TestParse(t *testing.T) {
 					Element: osm.Element{
 						ID:       4533952893,
 						Tags:     osm.Tags{"amenity": "hospital", "name": "Кожно-венерологический диспансер", "name:ru": "Кожно-венерологический диспансер"},
-						Metadata: &osm.Metadata{UserID: 4112953, UserName: "Sergei97", Version: 1, Timestamp: time.Date(2016, 12, 2, 14, 15, 19, 0, time.UTC), Changeset: 44115157},
+						Metadata: &osm.Metadata{UserID: 4112953, UserName: "Sergei97", Version: 1, Timestamp: time.Date(2016, 12, 2, 14, 15, 19, 0, time.UTC), Changeset: 44115157, Visible: true},
 					},
 					Lat:  52.563681,
 					Long: 24.4658314,
@@ -113,7 +114,7 @@ func TestParse(t *testing.T) {
 					Element: osm.Element{
 						ID:       6863685,
 						Tags:     osm.Tags{"highway": "unclassified", "maxspeed": "30", "name": "Oranjestraat", "oneway": "yes", "cycleway": "opposite"},
-						Metadata: &osm.Metadata{UserID: 619707, UserName: "openMvD", Version: 6, Timestamp: time.Date(2016, 12, 2, 14, 15, 6, 0, time.UTC), Changeset: 44115110},
+						Metadata: &osm.Metadata{UserID: 619707, UserName: "openMvD", Version: 6, Timestamp: time.Date(2016, 12, 2, 14, 15, 6, 0, time.UTC), Changeset: 44115110, Visible: true},
 					},
 					Refs:  []int64{44776397, 44776575, 4534010578, 44776865, 4534010576, 44780387},
 					Nodes: nil,
@@ -130,7 +131,7 @@ func TestParse(t *testing.T) {
 					Element: osm.Element{
 						ID:       2139646,
 						Tags:     osm.Tags{"destination": "Balonne River", "name": "Condamine River", "type": "waterway", "waterway": "river", "wikidata": "Q805500", "wikipedia": "en:Condamine River"},
-						Metadata: &osm.Metadata{UserID: 1185091, UserName: "nick0252", Version: 13, Timestamp: time.Date(2016, 12, 2, 14, 15, 32, 0, time.UTC), Changeset: 44115162},
+						Metadata: &osm.Metadata{UserID: 1185091, UserName: "nick0252", Version: 13, Timestamp: time.Date(2016, 12, 2, 14, 15, 32, 0, time.UTC), Changeset: 44115162, Visible: true},
 					},
 					Members: []osm.Member{
 						{ID: 142320051, Type: 1, Role: "main_stream"},
@@ -167,3 +168,148 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStrict(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{
+			name: "invalid id",
+			doc:  `<osmChange version="0.6"><create><node id="12x" lat="1" lon="2"/></create></osmChange>`,
+			err:  `line 1, column 66 (offset 65): invalid node: invalid id: strconv.ParseInt: parsing "12x": invalid syntax`,
+		},
+		{
+			name: "invalid lat",
+			doc:  "<osmChange version=\"0.6\">\n<modify>\n<node id=\"1\" lat=\"\" lon=\"2\"/>\n</modify></osmChange>",
+			err:  `line 3, column 30 (offset 64): invalid node: invalid lat: strconv.ParseFloat: parsing "": invalid syntax`,
+		},
+		{
+			name: "invalid ref",
+			doc:  `<osmChange version="0.6"><create><way id="1"><nd ref="a"/></way></create></osmChange>`,
+			err:  "invalid nd: invalid ref",
+		},
+		{
+			name: "invalid member type",
+			doc:  `<osmChange version="0.6"><create><relation id="1"><member type="area" ref="1" role=""/></relation></create></osmChange>`,
+			err:  `invalid member: unknown member type "area"`,
+		},
+		{
+			name: "invalid timestamp",
+			doc:  `<osmChange version="0.6"><create><way id="1" timestamp="yesterday"/></create></osmChange>`,
+			err:  "invalid way: invalid timestamp",
+		},
+		{
+			name: "unknown action",
+			doc:  `<osmChange version="0.6"><update><way id="1"/></update></osmChange>`,
+			err:  "unknown action update",
+		},
+		{
+			name: "outside of action",
+			doc:  `<osmChange version="0.6"><create></create><way id="1"/></osmChange>`,
+			err:  "way outside of create, modify or delete",
+		},
+		{
+			name: "unexpected EOF",
+			doc:  `<osmChange version="0.6"><create><way id="1"/>`,
+			err:  "unexpected EOF",
+		},
+		{
+			name: "invalid root",
+			doc:  `<osm version="0.6"><node id="1"/></osm>`,
+			err:  "invalid root element osm, expected osmChange",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := Config{
+				Diffs:           make(chan osm.Diff),
+				IncludeMetadata: true,
+				Strict:          true,
+			}
+			go func() {
+				for range conf.Diffs {
+				}
+			}()
+			err := New(strings.NewReader(tc.doc), conf).Parse(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected error with %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	conf := Config{Diffs: make(chan osm.Diff, 10)}
+	doc := `<osmChange version="0.6"><create><node id="12x" lat="1" lon="2"/><relation id="1"><member type="area" ref="1" role=""/></relation></create></osmChange>`
+	if err := New(strings.NewReader(doc), conf).Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	diffs := []osm.Diff{}
+	for d := range conf.Diffs {
+		diffs = append(diffs, d)
+	}
+	if len(diffs) != 2 || diffs[0].Node.ID != 0 || diffs[0].Node.Lat != 1 || len(diffs[1].Rel.Members) != 0 {
+		t.Errorf("unexpected diffs %#v", diffs)
+	}
+}
+
+func TestParseCancel(t *testing.T) {
+	conf := Config{
+		Diffs: make(chan osm.Diff),
+	}
+	f, err := os.Open("612.osc.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p, err := NewGZIP(f, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	received := 0
+	go func() {
+		// keep reading after cancel, no more diffs should be sent
+		for range conf.Diffs {
+			received++
+			if received == 10 {
+				stop()
+			}
+		}
+	}()
+
+	if err := p.Parse(ctx); err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if err := p.Parse(context.Background()); err != context.Canceled {
+		t.Error("expected context.Canceled from second Parse, got", err)
+	}
+}
+
+func TestParseCancelWithoutReceiver(t *testing.T) {
+	conf := Config{
+		Diffs: make(chan osm.Diff),
+	}
+	f, err := os.Open("612.osc.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p, err := NewGZIP(f, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	stop()
+	if err := p.Parse(ctx); err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if _, ok := <-conf.Diffs; ok {
+		t.Error("Diffs not closed after cancel")
+	}
+}
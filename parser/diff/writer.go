@@ -0,0 +1,167 @@
+package diff
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+	"github.com/omniscale/go-osm/parser/internal/xmlelem"
+)
+
+// flushSize is the size of the internal buffer that triggers a write to the
+// underlying io.Writer.
+const flushSize = 64 * 1024
+
+type WriterConfig struct {
+	// Generator is written as generator attribute of the osmChange
+	// element. Defaults to github.com/omniscale/go-osm.
+	Generator string
+}
+
+type action int
+
+const (
+	noAction action = iota
+	createAction
+	modifyAction
+	deleteAction
+)
+
+var actionNames = [...]string{
+	createAction: "create",
+	modifyAction: "modify",
+	deleteAction: "delete",
+}
+
+// Writer encodes diff elements into an OSM diff file (.osc).
+//
+// Consecutive elements with the same action are grouped into a single
+// create, modify or delete section. The metadata of each element is written
+// if it is not nil.
+type Writer struct {
+	w             io.Writer
+	gz            *gzip.Writer
+	conf          WriterConfig
+	headerWritten bool
+	action        action
+	buf           []byte
+	err           error
+}
+
+// NewWriter creates a new diff writer for the provided output. Close needs
+// to be called after all diffs are written.
+func NewWriter(w io.Writer, conf WriterConfig) *Writer {
+	return &Writer{w: w, conf: conf}
+}
+
+// NewGZIPWriter creates a new diff writer for GZIP compressed output
+// (.osc.gz), as used by replication servers. Close flushes the GZIP stream.
+func NewGZIPWriter(w io.Writer, conf WriterConfig) *Writer {
+	gz := gzip.NewWriter(w)
+	return &Writer{w: gz, gz: gz, conf: conf}
+}
+
+// Write writes a single diff element. Exactly one of Create, Modify or
+// Delete needs to be set, and one of Node, Way or Rel.
+func (w *Writer) Write(d osm.Diff) error {
+	if w.err != nil {
+		return w.err
+	}
+	var a action
+	switch {
+	case d.Create && !d.Modify && !d.Delete:
+		a = createAction
+	case d.Modify && !d.Create && !d.Delete:
+		a = modifyAction
+	case d.Delete && !d.Create && !d.Modify:
+		a = deleteAction
+	default:
+		return fmt.Errorf("diff requires exactly one of create, modify or delete: %+v", d)
+	}
+	if d.Node == nil && d.Way == nil && d.Rel == nil {
+		return errors.New("diff without node, way or relation")
+	}
+
+	w.writeHeader()
+	if a != w.action {
+		w.endAction()
+		w.buf = append(w.buf, "  <"...)
+		w.buf = append(w.buf, actionNames[a]...)
+		w.buf = append(w.buf, ">\n"...)
+		w.action = a
+	}
+
+	switch {
+	case d.Node != nil:
+		w.buf = xmlelem.AppendNode(w.buf, "    ", d.Node, true)
+	case d.Way != nil:
+		w.buf = xmlelem.AppendWay(w.buf, "    ", d.Way, true)
+	case d.Rel != nil:
+		w.buf = xmlelem.AppendRelation(w.buf, "    ", d.Rel, true)
+	}
+
+	if len(w.buf) >= flushSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes all pending elements and the end of the document. It does
+// not close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.writeHeader()
+	w.endAction()
+	w.buf = append(w.buf, "</osmChange>\n"...)
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			w.err = fmt.Errorf("closing gzip writer: %w", err)
+			return w.err
+		}
+	}
+	w.err = stream.ErrWriterClosed
+	return nil
+}
+
+func (w *Writer) writeHeader() {
+	if w.headerWritten {
+		return
+	}
+	w.headerWritten = true
+	generator := w.conf.Generator
+	if generator == "" {
+		generator = stream.DefaultGenerator
+	}
+	w.buf = append(w.buf, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"...)
+	w.buf = append(w.buf, `<osmChange version="0.6" generator="`...)
+	w.buf = xmlelem.AppendEscaped(w.buf, generator)
+	w.buf = append(w.buf, "\">\n"...)
+}
+
+// endAction closes the current create, modify or delete section.
+func (w *Writer) endAction() {
+	if w.action == noAction {
+		return
+	}
+	w.buf = append(w.buf, "  </"...)
+	w.buf = append(w.buf, actionNames[w.action]...)
+	w.buf = append(w.buf, ">\n"...)
+	w.action = noAction
+}
+
+func (w *Writer) flush() error {
+	if _, err := w.w.Write(w.buf); err != nil {
+		w.err = fmt.Errorf("writing diff: %w", err)
+		return w.err
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
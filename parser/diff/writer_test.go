@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+)
+
+func parseDiffs(t *testing.T, r io.Reader) []osm.Diff {
+	t.Helper()
+	conf := Config{
+		Diffs:           make(chan osm.Diff),
+		IncludeMetadata: true,
+	}
+	p, err := NewGZIP(r, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan []osm.Diff)
+	go func() {
+		diffs := []osm.Diff{}
+		for d := range conf.Diffs {
+			diffs = append(diffs, d)
+		}
+		done <- diffs
+	}()
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return <-done
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	f, err := os.Open("612.osc.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parseDiffs(t, f)
+	f.Close()
+
+	buf := &bytes.Buffer{}
+	w := NewGZIPWriter(buf, WriterConfig{})
+	for _, d := range want {
+		if err := w.Write(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := parseDiffs(t, buf)
+	if len(got) != len(want) {
+		t.Fatalf("got %d diffs, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Fatalf("diff %d differs after round trip\n%#v\n%#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWriterOutput(t *testing.T) {
+	md := &osm.Metadata{Version: 2, Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Changeset: 100, UserID: 42, UserName: "foo", Visible: true}
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{Generator: "test"})
+	for _, d := range []osm.Diff{
+		{Create: true, Node: &osm.Node{Element: osm.Element{ID: 1, Tags: osm.Tags{"name": "a&b"}}, Lat: 1, Long: 2}},
+		{Create: true, Way: &osm.Way{Element: osm.Element{ID: 2}, Refs: []int64{1}}},
+		{Modify: true, Rel: &osm.Relation{Element: osm.Element{ID: 3, Metadata: md}, Members: []osm.Member{{ID: 2, Type: osm.WayMember}}}},
+		{Create: true, Node: &osm.Node{Element: osm.Element{ID: 4}, Lat: 3, Long: 4}},
+		{Delete: true, Node: &osm.Node{Element: osm.Element{ID: 5}}},
+	} {
+		if err := w.Write(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6" generator="test">
+  <create>
+    <node id="1" lat="1" lon="2">
+      <tag k="name" v="a&amp;b"/>
+    </node>
+    <way id="2">
+      <nd ref="1"/>
+    </way>
+  </create>
+  <modify>
+    <relation id="3" version="2" timestamp="2020-01-02T03:04:05Z" changeset="100" uid="42" user="foo" visible="true">
+      <member type="way" ref="2" role=""/>
+    </relation>
+  </modify>
+  <create>
+    <node id="4" lat="3" lon="4"/>
+  </create>
+  <delete>
+    <node id="5" lat="0" lon="0"/>
+  </delete>
+</osmChange>
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriterInvalid(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, WriterConfig{})
+	nd := &osm.Node{Element: osm.Element{ID: 1}}
+	if err := w.Write(osm.Diff{Node: nd}); err == nil {
+		t.Error("expected error for diff without action")
+	}
+	if err := w.Write(osm.Diff{Create: true, Delete: true, Node: nd}); err == nil {
+		t.Error("expected error for diff with multiple actions")
+	}
+	if err := w.Write(osm.Diff{Create: true}); err == nil {
+		t.Error("expected error for diff without element")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(osm.Diff{Create: true, Node: nd}); err != stream.ErrWriterClosed {
+		t.Error("expected error after Close, got", err)
+	}
+}
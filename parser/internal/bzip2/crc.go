@@ -0,0 +1,23 @@
+package bzip2
+
+// crcTable is the table of the big-endian CRC-32 (polynomial 0x04c11db7)
+// used by BZIP2.
+var crcTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04c11db7
+			} else {
+				c <<= 1
+			}
+		}
+		table[i] = c
+	}
+	return table
+}()
+
+func updateCRC(crc uint32, b byte) uint32 {
+	return crc<<8 ^ crcTable[byte(crc>>24)^b]
+}
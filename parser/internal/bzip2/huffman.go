@@ -0,0 +1,85 @@
+package bzip2
+
+import "sort"
+
+// codeLengths sets the Huffman code lengths for the symbol frequencies.
+// Unused symbols get a code as well, as all symbols of the alphabet need
+// a length. The frequencies are scaled down till no code is longer than
+// maxLength.
+func codeLengths(lengths []uint8, freq []int32, maxLength int) {
+	n := len(freq)
+	weights := make([]int64, n)
+	for i, f := range freq {
+		weights[i] = max(int64(f), 1)
+	}
+
+	leaves := make([]int, n)
+	parent := make([]int, 2*n-1)
+	nodeWeight := make([]int64, 2*n-1)
+	for {
+		for i := range leaves {
+			leaves[i] = i
+			nodeWeight[i] = weights[i]
+		}
+		sort.Slice(leaves, func(i, j int) bool { return weights[leaves[i]] < weights[leaves[j]] })
+
+		// two queues: the sorted leaves and the internal nodes, which are
+		// created in order of their weight
+		nextLeaf, nextNode, numNodes := 0, n, n
+		pop := func() int {
+			if nextLeaf < n && (nextNode == numNodes || nodeWeight[leaves[nextLeaf]] <= nodeWeight[nextNode]) {
+				nextLeaf++
+				return leaves[nextLeaf-1]
+			}
+			nextNode++
+			return nextNode - 1
+		}
+		for numNodes < 2*n-1 {
+			a, b := pop(), pop()
+			nodeWeight[numNodes] = nodeWeight[a] + nodeWeight[b]
+			parent[a], parent[b] = numNodes, numNodes
+			numNodes++
+		}
+
+		tooLong := false
+		for i := range lengths {
+			depth := 0
+			for p := i; p != numNodes-1; p = parent[p] {
+				depth++
+			}
+			if depth > maxLength {
+				tooLong = true
+			}
+			lengths[i] = uint8(depth)
+		}
+		if !tooLong {
+			return
+		}
+		for i := range weights {
+			weights[i] = 1 + weights[i]/2
+		}
+	}
+}
+
+// canonicalCodes returns the canonical Huffman codes for the lengths.
+// Shorter codes come first, codes with the same length are ordered by
+// their symbol.
+func canonicalCodes(lengths []uint8) []uint32 {
+	codes := make([]uint32, len(lengths))
+	minLen, maxLen := lengths[0], lengths[0]
+	for _, l := range lengths {
+		minLen = min(minLen, l)
+		maxLen = max(maxLen, l)
+	}
+	code := uint32(0)
+	for l := minLen; l <= maxLen; l++ {
+		for s, sl := range lengths {
+			if sl == l {
+				codes[s] = code
+				code++
+			}
+		}
+		code <<= 1
+	}
+	return codes
+}
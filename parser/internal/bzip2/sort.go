@@ -0,0 +1,70 @@
+package bzip2
+
+import "sort"
+
+// sortRotations returns the start positions of all rotations of b in
+// sorted order. sa, rank and tmp need to have the same length as b.
+//
+// The rotations are sorted by prefix doubling: after each round, the
+// rotations are sorted by their first k bytes and rank contains the class
+// of each rotation. Rotations are sorted by the classes of their first and
+// second half in the next round. Identical rotations (of periodic blocks)
+// are in arbitrary order, as this does not change the transform.
+func sortRotations(b []byte, sa, rank, tmp []int32) []int32 {
+	n := len(b)
+	for i := range sa {
+		sa[i] = int32(i)
+	}
+	sort.Slice(sa, func(i, j int) bool { return b[sa[i]] < b[sa[j]] })
+	classes := int32(0)
+	for i := range sa {
+		if i > 0 && b[sa[i]] != b[sa[i-1]] {
+			classes++
+		}
+		rank[sa[i]] = classes
+	}
+
+	count := make([]int32, n+1)
+	for k := 1; k < n && int(classes) < n-1; k *= 2 {
+		// sa is sorted by the first k bytes, so the rotations starting k
+		// bytes earlier are sorted by their second half
+		for i, p := range sa {
+			p -= int32(k)
+			if p < 0 {
+				p += int32(n)
+			}
+			tmp[i] = p
+		}
+		// stable counting sort by the first half
+		clear(count)
+		for _, p := range tmp {
+			count[rank[p]+1]++
+		}
+		for i := 1; i < len(count); i++ {
+			count[i] += count[i-1]
+		}
+		for _, p := range tmp {
+			sa[count[rank[p]]] = p
+			count[rank[p]]++
+		}
+
+		second := func(p int32) int32 {
+			p += int32(k)
+			if p >= int32(n) {
+				p -= int32(n)
+			}
+			return rank[p]
+		}
+		classes = 0
+		tmp[sa[0]] = 0
+		for i := 1; i < n; i++ {
+			cur, prev := sa[i], sa[i-1]
+			if rank[cur] != rank[prev] || second(cur) != second(prev) {
+				classes++
+			}
+			tmp[cur] = classes
+		}
+		copy(rank, tmp)
+	}
+	return sa
+}
@@ -0,0 +1,446 @@
+// Package bzip2 implements a BZIP2 compressor, as the standard library
+// only provides a BZIP2 decompressor (compress/bzip2).
+//
+// The output is compatible with the reference bzip2 implementation, but
+// the compression is slower and slightly worse, as the encoder does not
+// use the optimizations of the reference implementation.
+package bzip2
+
+import (
+	"errors"
+	"io"
+)
+
+// level is the block size in 100k. 9 is the default of the reference
+// implementation.
+const level = 9
+
+// maxBlockLen is the maximum number of bytes in a block after the initial
+// run-length encoding, with the same margin as the reference
+// implementation.
+const maxBlockLen = level*100000 - 19
+
+const (
+	blockMagic1 = 0x314159 // BCD of pi
+	blockMagic2 = 0x265359
+	endMagic1   = 0x177245 // BCD of sqrt(pi)
+	endMagic2   = 0x385090
+)
+
+const (
+	runA = 0
+	runB = 1
+
+	groupSize     = 50
+	maxCodeLength = 17
+	numIterations = 4
+)
+
+var errClosed = errors.New("bzip2: writer already closed")
+
+// Writer compresses all written data as BZIP2 stream.
+type Writer struct {
+	w   io.Writer
+	bw  bitWriter
+	err error
+
+	headerWritten bool
+	// block contains the run-length encoded data of the current block
+	block     []byte
+	blockCRC  uint32
+	streamCRC uint32
+	runByte   byte
+	runLen    int
+
+	// buffers that are reused for each block
+	sa, rank, tmp []int32
+	bwt           []byte
+	mtf           []uint16
+}
+
+// NewWriter returns a new Writer that writes the compressed data to w.
+// Close needs to be called to write the end of the stream.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, blockCRC: 0xffffffff}
+}
+
+// Write compresses p. The data is written to the underlying io.Writer for
+// each completed block (about 900kB of input).
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	for i, c := range p {
+		if z.runLen > 0 && c == z.runByte && z.runLen < 255 {
+			z.runLen++
+			continue
+		}
+		if err := z.flushRun(); err != nil {
+			return i, err
+		}
+		z.runByte, z.runLen = c, 1
+	}
+	return len(p), nil
+}
+
+// Close writes the remaining data and the end of the stream. It does not
+// close the underlying io.Writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if err := z.flushRun(); err != nil {
+		return err
+	}
+	if err := z.writeBlock(); err != nil {
+		return err
+	}
+	z.writeHeader()
+	z.bw.writeBits(24, endMagic1)
+	z.bw.writeBits(24, endMagic2)
+	z.bw.writeBits(32, uint64(z.streamCRC))
+	z.bw.pad()
+	if err := z.flushBits(); err != nil {
+		return err
+	}
+	z.err = errClosed
+	return nil
+}
+
+// flushRun adds the current run to the block. Runs of 4 to 255 bytes are
+// encoded as 4 bytes and the number of the remaining bytes.
+func (z *Writer) flushRun() error {
+	if z.runLen == 0 {
+		return nil
+	}
+	if len(z.block)+5 > maxBlockLen {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < z.runLen; i++ {
+		z.blockCRC = updateCRC(z.blockCRC, z.runByte)
+	}
+	if z.runLen < 4 {
+		for i := 0; i < z.runLen; i++ {
+			z.block = append(z.block, z.runByte)
+		}
+	} else {
+		z.block = append(z.block, z.runByte, z.runByte, z.runByte, z.runByte, byte(z.runLen-4))
+	}
+	z.runLen = 0
+	return nil
+}
+
+func (z *Writer) writeHeader() {
+	if z.headerWritten {
+		return
+	}
+	z.headerWritten = true
+	z.bw.writeBits(24, 'B'<<16|'Z'<<8|'h')
+	z.bw.writeBits(8, '0'+level)
+}
+
+func (z *Writer) flushBits() error {
+	if _, err := z.w.Write(z.bw.buf); err != nil {
+		z.err = err
+		return err
+	}
+	z.bw.buf = z.bw.buf[:0]
+	return nil
+}
+
+// writeBlock compresses and writes the current block.
+func (z *Writer) writeBlock() error {
+	if len(z.block) == 0 {
+		return nil
+	}
+	z.writeHeader()
+
+	crc := ^z.blockCRC
+	z.streamCRC = (z.streamCRC<<1 | z.streamCRC>>31) ^ crc
+
+	z.bw.writeBits(24, blockMagic1)
+	z.bw.writeBits(24, blockMagic2)
+	z.bw.writeBits(32, uint64(crc))
+	z.bw.writeBits(1, 0) // not randomized
+
+	origPtr, bwt := z.transform()
+	z.bw.writeBits(24, uint64(origPtr))
+
+	var inUse [256]bool
+	for _, c := range z.block {
+		inUse[c] = true
+	}
+	var used16 uint64
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				used16 |= 1 << (15 - i)
+				break
+			}
+		}
+	}
+	z.bw.writeBits(16, used16)
+	for i := 0; i < 16; i++ {
+		if used16&(1<<(15-i)) == 0 {
+			continue
+		}
+		var used uint64
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				used |= 1 << (15 - j)
+			}
+		}
+		z.bw.writeBits(16, used)
+	}
+
+	mtf, alphaSize := z.moveToFront(bwt, &inUse)
+	z.writeSymbols(mtf, alphaSize)
+
+	z.block = z.block[:0]
+	z.blockCRC = 0xffffffff
+	return z.flushBits()
+}
+
+// transform returns the Burrows-Wheeler transform of the block and the
+// position of the original block in the sorted rotations.
+func (z *Writer) transform() (int, []byte) {
+	n := len(z.block)
+	z.sa = sortRotations(z.block, resize(z.sa, n), resize(z.rank, n), resize(z.tmp, n))
+	if cap(z.bwt) < n {
+		z.bwt = make([]byte, n)
+	}
+	bwt := z.bwt[:n]
+	origPtr := 0
+	for i, p := range z.sa {
+		if p == 0 {
+			origPtr = i
+			bwt[i] = z.block[n-1]
+		} else {
+			bwt[i] = z.block[p-1]
+		}
+	}
+	return origPtr, bwt
+}
+
+// moveToFront returns the move-to-front encoding of bwt with runs of
+// zeros encoded as runA/runB symbols, terminated by the end-of-block
+// symbol. The symbols only index the used bytes.
+func (z *Writer) moveToFront(bwt []byte, inUse *[256]bool) ([]uint16, int) {
+	var unseqToSeq [256]byte
+	var order [256]byte
+	numInUse := 0
+	for i, used := range inUse {
+		if used {
+			unseqToSeq[i] = byte(numInUse)
+			order[numInUse] = byte(numInUse)
+			numInUse++
+		}
+	}
+	alphaSize := numInUse + 2
+	eob := uint16(numInUse + 1)
+
+	mtf := z.mtf[:0]
+	zeros := 0
+	flushZeros := func() {
+		if zeros == 0 {
+			return
+		}
+		// bijective base-2 encoding of the run length
+		zeros--
+		for {
+			if zeros&1 == 1 {
+				mtf = append(mtf, runB)
+			} else {
+				mtf = append(mtf, runA)
+			}
+			if zeros < 2 {
+				break
+			}
+			zeros = (zeros - 2) / 2
+		}
+		zeros = 0
+	}
+	for _, c := range bwt {
+		s := unseqToSeq[c]
+		if order[0] == s {
+			zeros++
+			continue
+		}
+		flushZeros()
+		j := 1
+		for order[j] != s {
+			j++
+		}
+		copy(order[1:j+1], order[:j])
+		order[0] = s
+		mtf = append(mtf, uint16(j+1))
+	}
+	flushZeros()
+	mtf = append(mtf, eob)
+	z.mtf = mtf
+	return mtf, alphaSize
+}
+
+// writeSymbols writes the Huffman tables, the selectors and the Huffman
+// coded symbols. Each group of 50 symbols is coded with the table that
+// results in the shortest output. The tables are optimized for the
+// selected groups in a few iterations, as in the reference implementation.
+func (z *Writer) writeSymbols(mtf []uint16, alphaSize int) {
+	numTables := 6
+	switch {
+	case len(mtf) < 200:
+		numTables = 2
+	case len(mtf) < 600:
+		numTables = 3
+	case len(mtf) < 1200:
+		numTables = 4
+	case len(mtf) < 2400:
+		numTables = 5
+	}
+
+	freq := make([]int32, alphaSize)
+	for _, s := range mtf {
+		freq[s]++
+	}
+
+	// initial tables prefer consecutive symbol ranges with similar
+	// frequencies
+	lengths := make([][]uint8, numTables)
+	remaining := int32(len(mtf))
+	start := 0
+	for part := numTables; part > 0; part-- {
+		target := remaining / int32(part)
+		end := start - 1
+		var sum int32
+		for sum < target && end < alphaSize-1 {
+			end++
+			sum += freq[end]
+		}
+		if end > start && part != numTables && part != 1 && (numTables-part)%2 == 1 {
+			sum -= freq[end]
+			end--
+		}
+		l := make([]uint8, alphaSize)
+		for s := range l {
+			if s >= start && s <= end {
+				l[s] = 0
+			} else {
+				l[s] = 15
+			}
+		}
+		lengths[part-1] = l
+		start = end + 1
+		remaining -= sum
+	}
+
+	numGroups := (len(mtf) + groupSize - 1) / groupSize
+	selectors := make([]uint8, numGroups)
+	tableFreq := make([][]int32, numTables)
+	for t := range tableFreq {
+		tableFreq[t] = make([]int32, alphaSize)
+	}
+	for iter := 0; iter < numIterations; iter++ {
+		for t := range tableFreq {
+			clear(tableFreq[t])
+		}
+		for g := range selectors {
+			group := mtf[g*groupSize : min((g+1)*groupSize, len(mtf))]
+			best, bestCost := 0, -1
+			for t, l := range lengths {
+				cost := 0
+				for _, s := range group {
+					cost += int(l[s])
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = t, cost
+				}
+			}
+			selectors[g] = uint8(best)
+			for _, s := range group {
+				tableFreq[best][s]++
+			}
+		}
+		for t := range lengths {
+			codeLengths(lengths[t], tableFreq[t], maxCodeLength)
+		}
+	}
+
+	z.bw.writeBits(3, uint64(numTables))
+	z.bw.writeBits(15, uint64(numGroups))
+	// selectors are move-to-front and unary encoded
+	var order [6]uint8
+	for i := range order {
+		order[i] = uint8(i)
+	}
+	for _, sel := range selectors {
+		j := 0
+		for order[j] != sel {
+			j++
+		}
+		copy(order[1:j+1], order[:j])
+		order[0] = sel
+		for ; j > 0; j-- {
+			z.bw.writeBits(1, 1)
+		}
+		z.bw.writeBits(1, 0)
+	}
+
+	// code lengths are delta encoded
+	codes := make([][]uint32, numTables)
+	for t, l := range lengths {
+		cur := l[0]
+		z.bw.writeBits(5, uint64(cur))
+		for _, length := range l {
+			for cur < length {
+				z.bw.writeBits(2, 2)
+				cur++
+			}
+			for cur > length {
+				z.bw.writeBits(2, 3)
+				cur--
+			}
+			z.bw.writeBits(1, 0)
+		}
+		codes[t] = canonicalCodes(l)
+	}
+
+	for g, sel := range selectors {
+		l, c := lengths[sel], codes[sel]
+		for _, s := range mtf[g*groupSize : min((g+1)*groupSize, len(mtf))] {
+			z.bw.writeBits(uint(l[s]), uint64(c[s]))
+		}
+	}
+}
+
+func resize(s []int32, n int) []int32 {
+	if cap(s) < n {
+		return make([]int32, n)
+	}
+	return s[:n]
+}
+
+// bitWriter collects bits, starting with the most significant bit.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+// writeBits writes the lower n bits of v. n needs to be 32 or less.
+func (b *bitWriter) writeBits(n uint, v uint64) {
+	b.acc = b.acc<<n | v&(1<<n-1)
+	b.nbits += n
+	for b.nbits >= 8 {
+		b.nbits -= 8
+		b.buf = append(b.buf, byte(b.acc>>b.nbits))
+	}
+}
+
+// pad writes the remaining bits with zero padding.
+func (b *bitWriter) pad() {
+	if b.nbits > 0 {
+		b.writeBits(8-b.nbits, 0)
+	}
+}
@@ -0,0 +1,75 @@
+package bzip2
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	rnd.Read(random)
+	text := bytes.Repeat([]byte("<node id=\"1\" lat=\"43.73\" lon=\"7.41\"/>\n"), 10000)
+	// runs longer than 255 bytes and with exactly 4 bytes
+	runs := append(bytes.Repeat([]byte{'a'}, 1000), "bbbbcccccdd"...)
+	// more than one block
+	large := make([]byte, 0, maxBlockLen+100000)
+	for len(large) < maxBlockLen+100000 {
+		large = append(large, random[:rnd.Intn(1000)]...)
+		large = append(large, text[:rnd.Intn(1000)]...)
+	}
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"single byte", []byte{'x'}},
+		{"periodic", bytes.Repeat([]byte("ab"), 1000)},
+		{"runs", runs},
+		{"random", random},
+		{"text", text},
+		{"large", large},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := NewWriter(buf)
+			// write in chunks to split runs between calls
+			for data := tc.data; len(data) > 0; {
+				n := min(len(data), 777)
+				if _, err := w.Write(data[:n]); err != nil {
+					t.Fatal(err)
+				}
+				data = data[n:]
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			compressed := buf.Len()
+
+			got, err := io.ReadAll(bzip2.NewReader(buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tc.data) {
+				t.Fatalf("data differs after round trip, got %d bytes, expected %d", len(got), len(tc.data))
+			}
+			if tc.name == "text" && compressed > len(tc.data)/100 {
+				t.Errorf("text not compressed, %d bytes for %d", compressed, len(tc.data))
+			}
+		})
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	w := NewWriter(io.Discard)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("foo")); err != errClosed {
+		t.Error("expected errClosed, got", err)
+	}
+}
@@ -0,0 +1,30 @@
+// Package stream contains the helpers that are shared by the parsers and
+// writers of the pbf, osmxml, diff and changeset packages.
+package stream
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultGenerator is written as generator or writing program if none is
+// configured.
+const DefaultGenerator = "github.com/omniscale/go-osm"
+
+// ErrWriterClosed is returned by writers for all calls after Close.
+var ErrWriterClosed = errors.New("writer already closed")
+
+// Send sends v to ch, unless ctx is canceled before. Returns ctx.Err() if
+// ctx is canceled.
+func Send[T any](ctx context.Context, ch chan<- T, v T) error {
+	// prefer ctx if the receiver is also ready
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ch <- v:
+		return nil
+	}
+}
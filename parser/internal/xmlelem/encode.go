@@ -0,0 +1,187 @@
+package xmlelem
+
+import (
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/omniscale/go-osm"
+)
+
+// AppendNode appends nd as node element to buf. Each line is prefixed with
+// indent. The attributes and tags are written as they are read by Builder.
+func AppendNode(buf []byte, indent string, nd *osm.Node, includeMD bool) []byte {
+	buf = append(buf, indent...)
+	buf = append(buf, `<node id="`...)
+	buf = strconv.AppendInt(buf, nd.ID, 10)
+	buf = append(buf, '"')
+	if includeMD {
+		buf = appendMetadata(buf, nd.Metadata)
+	}
+	if !includeMD || nd.Metadata == nil || nd.Metadata.Visible {
+		// deleted nodes have no location
+		buf = append(buf, ` lat="`...)
+		buf = strconv.AppendFloat(buf, nd.Lat, 'f', -1, 64)
+		buf = append(buf, `" lon="`...)
+		buf = strconv.AppendFloat(buf, nd.Long, 'f', -1, 64)
+		buf = append(buf, '"')
+	}
+	if len(nd.Tags) == 0 {
+		return append(buf, "/>\n"...)
+	}
+	buf = append(buf, ">\n"...)
+	buf = appendTags(buf, indent, nd.Tags)
+	buf = append(buf, indent...)
+	return append(buf, "</node>\n"...)
+}
+
+// AppendWay appends w as way element with nd elements for all refs.
+func AppendWay(buf []byte, indent string, w *osm.Way, includeMD bool) []byte {
+	buf = append(buf, indent...)
+	buf = append(buf, `<way id="`...)
+	buf = strconv.AppendInt(buf, w.ID, 10)
+	buf = append(buf, '"')
+	if includeMD {
+		buf = appendMetadata(buf, w.Metadata)
+	}
+	if len(w.Refs) == 0 && len(w.Tags) == 0 {
+		return append(buf, "/>\n"...)
+	}
+	buf = append(buf, ">\n"...)
+	for _, ref := range w.Refs {
+		buf = append(buf, indent...)
+		buf = append(buf, `  <nd ref="`...)
+		buf = strconv.AppendInt(buf, ref, 10)
+		buf = append(buf, "\"/>\n"...)
+	}
+	buf = appendTags(buf, indent, w.Tags)
+	buf = append(buf, indent...)
+	return append(buf, "</way>\n"...)
+}
+
+// AppendRelation appends r as relation element with member elements.
+func AppendRelation(buf []byte, indent string, r *osm.Relation, includeMD bool) []byte {
+	buf = append(buf, indent...)
+	buf = append(buf, `<relation id="`...)
+	buf = strconv.AppendInt(buf, r.ID, 10)
+	buf = append(buf, '"')
+	if includeMD {
+		buf = appendMetadata(buf, r.Metadata)
+	}
+	if len(r.Members) == 0 && len(r.Tags) == 0 {
+		return append(buf, "/>\n"...)
+	}
+	buf = append(buf, ">\n"...)
+	for _, m := range r.Members {
+		buf = append(buf, indent...)
+		buf = append(buf, `  <member type="`...)
+		buf = append(buf, memberTypeNames[m.Type]...)
+		buf = append(buf, `" ref="`...)
+		buf = strconv.AppendInt(buf, m.ID, 10)
+		buf = append(buf, `" role="`...)
+		buf = AppendEscaped(buf, m.Role)
+		buf = append(buf, "\"/>\n"...)
+	}
+	buf = appendTags(buf, indent, r.Tags)
+	buf = append(buf, indent...)
+	return append(buf, "</relation>\n"...)
+}
+
+// appendTags appends a tag element for each tag, sorted by key.
+func appendTags(buf []byte, indent string, tags osm.Tags) []byte {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf = append(buf, indent...)
+		buf = append(buf, `  <tag k="`...)
+		buf = AppendEscaped(buf, k)
+		buf = append(buf, `" v="`...)
+		buf = AppendEscaped(buf, tags[k])
+		buf = append(buf, "\"/>\n"...)
+	}
+	return buf
+}
+
+// appendMetadata appends the metadata attributes that are read by Builder.
+// The timestamp is omitted if it is zero.
+func appendMetadata(buf []byte, md *osm.Metadata) []byte {
+	if md == nil {
+		return buf
+	}
+	buf = append(buf, ` version="`...)
+	buf = strconv.AppendInt(buf, int64(md.Version), 10)
+	if !md.Timestamp.IsZero() {
+		buf = append(buf, `" timestamp="`...)
+		buf = md.Timestamp.UTC().AppendFormat(buf, time.RFC3339)
+	}
+	buf = append(buf, `" changeset="`...)
+	buf = strconv.AppendInt(buf, md.Changeset, 10)
+	buf = append(buf, `" uid="`...)
+	buf = strconv.AppendInt(buf, int64(md.UserID), 10)
+	buf = append(buf, `" user="`...)
+	buf = AppendEscaped(buf, md.UserName)
+	buf = append(buf, `" visible="`...)
+	buf = strconv.AppendBool(buf, md.Visible)
+	return append(buf, '"')
+}
+
+// AppendEscaped appends s escaped for the use in XML attribute values.
+// Invalid UTF-8 and characters that are not allowed in XML are replaced
+// with U+FFFD.
+func AppendEscaped(buf []byte, s string) []byte {
+	return appendEscaped(buf, s, true)
+}
+
+// AppendEscapedText appends s escaped for the use as XML character data.
+// Tabs and newlines are kept as is.
+func AppendEscapedText(buf []byte, s string) []byte {
+	return appendEscaped(buf, s, false)
+}
+
+func appendEscaped(buf []byte, s string, attr bool) []byte {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '&':
+			buf = append(buf, "&amp;"...)
+		case r == '<':
+			buf = append(buf, "&lt;"...)
+		case r == '>':
+			buf = append(buf, "&gt;"...)
+		case r == '"' && attr:
+			buf = append(buf, "&quot;"...)
+		case r == '\'' && attr:
+			buf = append(buf, "&apos;"...)
+		case r == '\t' && attr:
+			buf = append(buf, "&#x9;"...)
+		case r == '\n' && attr:
+			buf = append(buf, "&#xA;"...)
+		case r == '\r':
+			buf = append(buf, "&#xD;"...)
+		case !isXMLChar(r) || (r == utf8.RuneError && size == 1):
+			buf = utf8.AppendRune(buf, utf8.RuneError)
+		default:
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return buf
+}
+
+// isXMLChar reports whether r is a valid XML 1.0 character.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+var memberTypeNames = map[osm.MemberType]string{
+	osm.NodeMember:     "node",
+	osm.WayMember:      "way",
+	osm.RelationMember: "relation",
+}
@@ -0,0 +1,229 @@
+// Package xmlelem builds nodes, ways and relations from the tokens of OSM
+// XML files. It is shared by the OSM XML and the diff (.osc) parsers.
+package xmlelem
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/omniscale/go-osm"
+)
+
+// Builder collects the XML tokens of a single node, way or relation.
+type Builder struct {
+	// IncludeMetadata indicates whether metadata like timestamps, versions
+	// and user names should be parsed.
+	IncludeMetadata bool
+
+	// Strict indicates whether invalid attribute values should be reported
+	// as error. Invalid values are set to zero and invalid members are
+	// ignored otherwise.
+	Strict bool
+
+	tags map[string]string
+	node *osm.Node
+	way  *osm.Way
+	rel  *osm.Relation
+	err  error
+
+	deleted bool
+}
+
+// Deleted returns whether the last node is marked as deleted
+// (visible="false"). Deleted nodes of historical files have no location.
+// Also available if IncludeMetadata is false.
+func (b *Builder) Deleted() bool {
+	return b.deleted
+}
+
+// Start handles the StartElement of nodes, ways, relations and their
+// nd, member and tag child elements. Other elements are ignored. Returns
+// an error for invalid attribute values in Strict mode.
+func (b *Builder) Start(tok xml.StartElement) error {
+	b.err = nil
+	switch tok.Name.Local {
+	case "node":
+		b.node = &osm.Node{}
+		b.deleted = false
+		for _, attr := range tok.Attr {
+			switch attr.Name.Local {
+			case "visible":
+				b.deleted = attr.Value == "false"
+			case "id":
+				b.node.ID = b.parseInt(attr)
+			case "lat":
+				b.node.Lat = b.parseFloat(attr)
+			case "lon":
+				b.node.Long = b.parseFloat(attr)
+			}
+		}
+		if b.IncludeMetadata {
+			b.setMetadata(tok.Attr, &b.node.Element)
+		}
+	case "way":
+		b.way = &osm.Way{}
+		for _, attr := range tok.Attr {
+			if attr.Name.Local == "id" {
+				b.way.ID = b.parseInt(attr)
+			}
+		}
+		if b.IncludeMetadata {
+			b.setMetadata(tok.Attr, &b.way.Element)
+		}
+	case "relation":
+		b.rel = &osm.Relation{}
+		for _, attr := range tok.Attr {
+			if attr.Name.Local == "id" {
+				b.rel.ID = b.parseInt(attr)
+			}
+		}
+		if b.IncludeMetadata {
+			b.setMetadata(tok.Attr, &b.rel.Element)
+		}
+	case "nd":
+		if b.way == nil {
+			return nil
+		}
+		for _, attr := range tok.Attr {
+			if attr.Name.Local == "ref" {
+				b.way.Refs = append(b.way.Refs, b.parseInt(attr))
+			}
+		}
+	case "member":
+		if b.rel == nil {
+			return nil
+		}
+		member := osm.Member{}
+		for _, attr := range tok.Attr {
+			switch attr.Name.Local {
+			case "type":
+				var ok bool
+				member.Type, ok = MemberTypeValues[attr.Value]
+				if !ok {
+					// ignore unknown member types
+					b.setErr(fmt.Errorf("unknown member type %q", attr.Value))
+					return b.err
+				}
+			case "role":
+				member.Role = attr.Value
+			case "ref":
+				var err error
+				member.ID, err = strconv.ParseInt(attr.Value, 10, 64)
+				if err != nil {
+					// ignore invalid ref
+					b.setErr(fmt.Errorf("invalid member ref: %w", err))
+					return b.err
+				}
+			}
+		}
+		b.rel.Members = append(b.rel.Members, member)
+	case "tag":
+		var k, v string
+		for _, attr := range tok.Attr {
+			if attr.Name.Local == "k" {
+				k = attr.Value
+			} else if attr.Name.Local == "v" {
+				v = attr.Value
+			}
+		}
+		if b.tags == nil {
+			b.tags = make(map[string]string)
+		}
+		b.tags[k] = v
+	}
+	return b.err
+}
+
+// End handles the EndElement of nodes, ways and relations and returns the
+// completed element. All results are nil for other elements.
+func (b *Builder) End(tok xml.EndElement) (*osm.Node, *osm.Way, *osm.Relation) {
+	var nd *osm.Node
+	var way *osm.Way
+	var rel *osm.Relation
+	switch tok.Name.Local {
+	case "node":
+		if b.node == nil {
+			return nil, nil, nil
+		}
+		nd, b.node = b.node, nil
+		nd.Tags = b.takeTags()
+	case "way":
+		if b.way == nil {
+			return nil, nil, nil
+		}
+		way, b.way = b.way, nil
+		way.Tags = b.takeTags()
+	case "relation":
+		if b.rel == nil {
+			return nil, nil, nil
+		}
+		rel, b.rel = b.rel, nil
+		rel.Tags = b.takeTags()
+	}
+	return nd, way, rel
+}
+
+func (b *Builder) takeTags() osm.Tags {
+	if len(b.tags) == 0 {
+		return nil
+	}
+	tags := b.tags
+	b.tags = nil
+	return tags
+}
+
+// setErr keeps the first error in Strict mode.
+func (b *Builder) setErr(err error) {
+	if b.Strict && b.err == nil {
+		b.err = err
+	}
+}
+
+func (b *Builder) parseInt(attr xml.Attr) int64 {
+	v, err := strconv.ParseInt(attr.Value, 10, 64)
+	if err != nil {
+		b.setErr(fmt.Errorf("invalid %s: %w", attr.Name.Local, err))
+	}
+	return v
+}
+
+func (b *Builder) parseFloat(attr xml.Attr) float64 {
+	v, err := strconv.ParseFloat(attr.Value, 64)
+	if err != nil {
+		b.setErr(fmt.Errorf("invalid %s: %w", attr.Name.Local, err))
+	}
+	return v
+}
+
+func (b *Builder) setMetadata(attrs []xml.Attr, elem *osm.Element) {
+	elem.Metadata = &osm.Metadata{Visible: true}
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "version":
+			elem.Metadata.Version = int32(b.parseInt(attr))
+		case "uid":
+			elem.Metadata.UserID = int32(b.parseInt(attr))
+		case "user":
+			elem.Metadata.UserName = attr.Value
+		case "changeset":
+			elem.Metadata.Changeset = b.parseInt(attr)
+		case "timestamp":
+			var err error
+			elem.Metadata.Timestamp, err = time.Parse(time.RFC3339, attr.Value)
+			if err != nil {
+				b.setErr(fmt.Errorf("invalid timestamp: %w", err))
+			}
+		case "visible":
+			elem.Metadata.Visible = attr.Value != "false"
+		}
+	}
+}
+
+// MemberTypeValues maps the type attribute of relation members.
+var MemberTypeValues = map[string]osm.MemberType{
+	"node":     osm.NodeMember,
+	"way":      osm.WayMember,
+	"relation": osm.RelationMember,
+}
@@ -0,0 +1,9 @@
+/*
+Package osmxml provides a stream based parser for OpenStreetMap XML files (.osm), as produced by the OSM API, Overpass or JOSM.
+
+Nodes, ways and relations are passed back in batches via channels, similar to the pbf package.
+
+The Writer encodes nodes, ways and relations as OSM XML file, uncompressed,
+GZIP or BZIP2 compressed.
+*/
+package osmxml
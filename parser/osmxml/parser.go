@@ -0,0 +1,378 @@
+package osmxml
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+	"github.com/omniscale/go-osm/parser/internal/xmlelem"
+)
+
+type Config struct {
+	// IncludeMetadata indicates whether metadata like timestamps, versions and
+	// user names should be parsed.
+	IncludeMetadata bool
+
+	// Nodes specifies the destination for parsed nodes. See also Coords below.
+	// For efficiency, multiple nodes are passed in batches.
+	Nodes chan []osm.Node
+	// Ways specifies the destination for parsed ways.
+	// For efficiency, multiple ways are passed in batches.
+	Ways chan []osm.Way
+	// Relations specifies the destination for parsed relations.
+	// For efficiency, multiple relations are passed in batches.
+	Relations chan []osm.Relation
+
+	// Coords specifies the destination for parsed nodes without any tags. This
+	// can be used for more efficient storage/proceessing of nodes that are
+	// only used as coordinates for ways and relations.
+	// For efficiency, multiple nodes are passed in batches.
+	//
+	// If a Coords channel is specified, then nodes without tags are
+	// not sent to the Nodes channel. However, the Coords channel will receive
+	// all nodes, except deleted nodes of files with historical information
+	// as they have no location.
+	Coords chan []osm.Node
+
+	// KeepOpen specifies whether the destination channels should be keept open
+	// after Parse(). By default, Nodes, Ways, Relations and Coords channels
+	// are closed after Parse().
+	KeepOpen bool
+}
+
+// Header contains the information from the root element and the bounds of
+// an OSM XML file.
+type Header struct {
+	// Version of the OSM XML format, e.g. 0.6.
+	Version string
+	// Generator is the name of the program that created this file.
+	Generator string
+	// BBox of the data (min lon, min lat, max lon, max lat) from the bounds
+	// element. BBox is zero if the file does not contain bounds.
+	BBox [4]float64
+}
+
+// Parser is a stream based parser for OSM XML files (.osm).
+type Parser struct {
+	decoder *xml.Decoder
+	conf    Config
+	header  *Header
+	// pending is the first token after the header that was already read by
+	// Header.
+	pending xml.Token
+	err     error
+}
+
+// New creates a new parser for the provided input. Config specifies the destinations for the parsed elements.
+func New(r io.Reader, conf Config) *Parser {
+	return &Parser{decoder: xml.NewDecoder(r), conf: conf}
+}
+
+// NewGZIP returns a parser from a GZIP compressed io.Reader
+func NewGZIP(r io.Reader, conf Config) (*Parser, error) {
+	r, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return New(r, conf), nil
+}
+
+// NewBZIP2 returns a parser from a BZIP2 compressed io.Reader
+func NewBZIP2(r io.Reader, conf Config) (*Parser, error) {
+	return New(bzip2.NewReader(r), conf), nil
+}
+
+// Error returns the first error that occurred during Header/Parse calls.
+func (p *Parser) Error() error {
+	return p.err
+}
+
+// Header returns the header information from the root element and the
+// bounds. Can be called before or after Parse().
+func (p *Parser) Header() (*Header, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.header == nil {
+		if p.err = p.parseHeader(); p.err != nil {
+			return nil, p.err
+		}
+	}
+	return p.header, nil
+}
+
+// parseHeader reads all tokens till the first node, way or relation.
+func (p *Parser) parseHeader() error {
+	header := &Header{}
+	root := false
+	for {
+		token, err := p.decoder.Token()
+		if err == io.EOF && root {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("decoding next XML token: %w", err)
+		}
+		tok, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if !root {
+			if tok.Name.Local != "osm" {
+				return errors.New("invalid root element, expected osm, got " + tok.Name.Local)
+			}
+			root = true
+			for _, attr := range tok.Attr {
+				switch attr.Name.Local {
+				case "version":
+					header.Version = attr.Value
+				case "generator":
+					header.Generator = attr.Value
+				}
+			}
+			continue
+		}
+		if tok.Name.Local == "bounds" {
+			header.BBox = parseBounds(tok.Attr)
+			continue
+		}
+		if tok.Name.Local == "node" || tok.Name.Local == "way" || tok.Name.Local == "relation" {
+			p.pending = tok.Copy()
+			break
+		}
+	}
+	p.header = header
+	return nil
+}
+
+func parseBounds(attrs []xml.Attr) [4]float64 {
+	var bbox [4]float64
+	for _, attr := range attrs {
+		v, _ := strconv.ParseFloat(attr.Value, 64)
+		switch attr.Name.Local {
+		case "minlon":
+			bbox[0] = v
+		case "minlat":
+			bbox[1] = v
+		case "maxlon":
+			bbox[2] = v
+		case "maxlat":
+			bbox[3] = v
+		}
+	}
+	return bbox
+}
+
+// Parse parses the OSM XML file and sends the parsed nodes, ways and
+// relations into the channels provided to the Parsers Config.
+// Context can be used to cancel the parsing; Parse returns ctx.Err() in
+// this case.
+func (p *Parser) Parse(ctx context.Context) (err error) {
+	if p.err != nil {
+		return p.err
+	}
+
+	defer func() {
+		if err != nil {
+			p.err = err
+		}
+	}()
+
+	if !p.conf.KeepOpen {
+		defer p.closeChannels()
+	}
+
+	if p.header == nil {
+		if err := p.parseHeader(); err != nil {
+			return err
+		}
+	}
+
+	builder := xmlelem.Builder{IncludeMetadata: p.conf.IncludeMetadata}
+	b := batches{conf: &p.conf}
+
+	for {
+		token := p.pending
+		p.pending = nil
+		if token == nil {
+			token, err = p.decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("decoding next XML token: %w", err)
+			}
+		}
+
+		switch tok := token.(type) {
+		case xml.StartElement:
+			builder.Start(tok)
+		case xml.EndElement:
+			if tok.Name.Local == "osm" {
+				return b.flush(ctx)
+			}
+			node, way, rel := builder.End(tok)
+			if node == nil && way == nil && rel == nil {
+				continue
+			}
+			// elements are sent in batches, stop before the next send
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if node != nil {
+				err = b.addNode(ctx, *node, builder.Deleted())
+			} else if way != nil {
+				err = b.addWay(ctx, *way)
+			} else if rel != nil {
+				err = b.addRelation(ctx, *rel)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return b.flush(ctx)
+}
+
+func (p *Parser) closeChannels() {
+	if p.conf.Coords != nil {
+		close(p.conf.Coords)
+	}
+	if p.conf.Nodes != nil {
+		close(p.conf.Nodes)
+	}
+	if p.conf.Ways != nil {
+		close(p.conf.Ways)
+	}
+	if p.conf.Relations != nil {
+		close(p.conf.Relations)
+	}
+}
+
+const batchSize = 8000
+
+// batches collects the parsed elements till BatchSize is reached or the
+// element type changes.
+type batches struct {
+	conf      *Config
+	coords    []osm.Node
+	nodes     []osm.Node
+	ways      []osm.Way
+	relations []osm.Relation
+}
+
+// addNode adds nd to the nodes and coords. Deleted nodes are not added to
+// the coords, as they have no location.
+func (b *batches) addNode(ctx context.Context, nd osm.Node, deleted bool) error {
+	if err := b.flushWays(ctx); err != nil {
+		return err
+	}
+	if err := b.flushRelations(ctx); err != nil {
+		return err
+	}
+	if b.conf.Coords != nil && !deleted {
+		coord := osm.Node{Element: osm.Element{ID: nd.ID}, Lat: nd.Lat, Long: nd.Long}
+		b.coords = append(b.coords, coord)
+		if len(b.coords) >= batchSize {
+			if err := b.flushNodes(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	if b.conf.Nodes != nil && (b.conf.Coords == nil || len(nd.Tags) > 0) {
+		b.nodes = append(b.nodes, nd)
+		if len(b.nodes) >= batchSize {
+			return b.flushNodes(ctx)
+		}
+	}
+	return nil
+}
+
+func (b *batches) addWay(ctx context.Context, w osm.Way) error {
+	if err := b.flushNodes(ctx); err != nil {
+		return err
+	}
+	if err := b.flushRelations(ctx); err != nil {
+		return err
+	}
+	if b.conf.Ways == nil {
+		return nil
+	}
+	b.ways = append(b.ways, w)
+	if len(b.ways) >= batchSize {
+		return b.flushWays(ctx)
+	}
+	return nil
+}
+
+func (b *batches) addRelation(ctx context.Context, r osm.Relation) error {
+	if err := b.flushNodes(ctx); err != nil {
+		return err
+	}
+	if err := b.flushWays(ctx); err != nil {
+		return err
+	}
+	if b.conf.Relations == nil {
+		return nil
+	}
+	b.relations = append(b.relations, r)
+	if len(b.relations) >= batchSize {
+		return b.flushRelations(ctx)
+	}
+	return nil
+}
+
+func (b *batches) flush(ctx context.Context) error {
+	if err := b.flushNodes(ctx); err != nil {
+		return err
+	}
+	if err := b.flushWays(ctx); err != nil {
+		return err
+	}
+	return b.flushRelations(ctx)
+}
+
+func (b *batches) flushNodes(ctx context.Context) error {
+	if len(b.coords) > 0 {
+		if err := stream.Send(ctx, b.conf.Coords, b.coords); err != nil {
+			return err
+		}
+		b.coords = nil
+	}
+	if len(b.nodes) > 0 {
+		if err := stream.Send(ctx, b.conf.Nodes, b.nodes); err != nil {
+			return err
+		}
+		b.nodes = nil
+	}
+	return nil
+}
+
+func (b *batches) flushWays(ctx context.Context) error {
+	if len(b.ways) == 0 {
+		return nil
+	}
+	if err := stream.Send(ctx, b.conf.Ways, b.ways); err != nil {
+		return err
+	}
+	b.ways = nil
+	return nil
+}
+
+func (b *batches) flushRelations(ctx context.Context) error {
+	if len(b.relations) == 0 {
+		return nil
+	}
+	if err := stream.Send(ctx, b.conf.Relations, b.relations); err != nil {
+		return err
+	}
+	b.relations = nil
+	return nil
+}
@@ -0,0 +1,282 @@
+package osmxml
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm"
+)
+
+type parsedElements struct {
+	coords    []osm.Node
+	nodes     []osm.Node
+	ways      []osm.Way
+	relations []osm.Relation
+}
+
+func parseAll(t *testing.T, p *Parser, conf Config) parsedElements {
+	t.Helper()
+	result := parsedElements{}
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	go func() {
+		if conf.Coords != nil {
+			for nds := range conf.Coords {
+				result.coords = append(result.coords, nds...)
+			}
+		}
+		wg.Done()
+	}()
+	go func() {
+		for nds := range conf.Nodes {
+			result.nodes = append(result.nodes, nds...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for ws := range conf.Ways {
+			result.ways = append(result.ways, ws...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for rs := range conf.Relations {
+			result.relations = append(result.relations, rs...)
+		}
+		wg.Done()
+	}()
+
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	return result
+}
+
+func newConf() Config {
+	return Config{
+		IncludeMetadata: true,
+		Nodes:           make(chan []osm.Node),
+		Ways:            make(chan []osm.Way),
+		Relations:       make(chan []osm.Relation),
+	}
+}
+
+func TestParse(t *testing.T) {
+	md := func(version int32, visible bool) *osm.Metadata {
+		return &osm.Metadata{
+			Version:   version,
+			Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Changeset: 100,
+			UserID:    42,
+			UserName:  "foo",
+			Visible:   visible,
+		}
+	}
+	wantNodes := []osm.Node{
+		{Element: osm.Element{ID: 1, Metadata: md(2, true)}, Lat: 43.73, Long: 7.41},
+		{Element: osm.Element{ID: 2, Metadata: md(1, true), Tags: osm.Tags{"name": "Café <Monaco>", "amenity": "cafe"}}, Lat: 43.74, Long: 7.42},
+		{Element: osm.Element{ID: 3, Metadata: &osm.Metadata{
+			Version:   3,
+			Timestamp: time.Date(2020, 1, 3, 3, 4, 5, 0, time.UTC),
+			Changeset: 101,
+			UserID:    43,
+			UserName:  "bar",
+			Visible:   false,
+		}}},
+	}
+	wantWays := []osm.Way{
+		{Element: osm.Element{ID: 10, Metadata: md(1, true), Tags: osm.Tags{"highway": "footway"}}, Refs: []int64{1, 2}},
+	}
+	wantRels := []osm.Relation{
+		{Element: osm.Element{ID: 20, Metadata: md(1, true), Tags: osm.Tags{"type": "multipolygon"}}, Members: []osm.Member{
+			{ID: 10, Type: osm.WayMember, Role: "outer"},
+			{ID: 2, Type: osm.NodeMember},
+		}},
+	}
+
+	for _, tc := range []struct {
+		name string
+		open func(io.Reader, Config) (*Parser, error)
+	}{
+		{name: "test.osm", open: func(r io.Reader, conf Config) (*Parser, error) { return New(r, conf), nil }},
+		{name: "test.osm.gz", open: NewGZIP},
+		{name: "test.osm.bz2", open: NewBZIP2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.Open(tc.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			conf := newConf()
+			p, err := tc.open(f, conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := parseAll(t, p, conf)
+
+			if !reflect.DeepEqual(got.nodes, wantNodes) {
+				t.Errorf("unexpected nodes %#v", got.nodes)
+			}
+			if !reflect.DeepEqual(got.ways, wantWays) {
+				t.Errorf("unexpected ways %#v", got.ways)
+			}
+			if !reflect.DeepEqual(got.relations, wantRels) {
+				t.Errorf("unexpected relations %#v", got.relations)
+			}
+
+			header, err := p.Header()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Header{Version: "0.6", Generator: "go-osm test", BBox: [4]float64{7.40, 43.72, 7.44, 43.76}}
+			if *header != want {
+				t.Errorf("unexpected header %#v", header)
+			}
+		})
+	}
+}
+
+func TestParseCoords(t *testing.T) {
+	f, err := os.Open("test.osm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	conf := newConf()
+	conf.IncludeMetadata = false
+	conf.Coords = make(chan []osm.Node)
+	got := parseAll(t, New(f, conf), conf)
+
+	// deleted node 3 has no location and is not added to the coords
+	if len(got.coords) != 2 || got.coords[0].ID != 1 || got.coords[1].ID != 2 {
+		t.Errorf("unexpected coords %#v", got.coords)
+	}
+	for _, nd := range got.coords {
+		if nd.Tags != nil || nd.Metadata != nil {
+			t.Errorf("unexpected coord %#v", nd)
+		}
+	}
+	// only nodes with tags if Coords is set
+	if len(got.nodes) != 1 || got.nodes[0].ID != 2 || got.nodes[0].Metadata != nil {
+		t.Errorf("unexpected nodes %#v", got.nodes)
+	}
+}
+
+func TestHeaderBeforeParse(t *testing.T) {
+	f, err := os.Open("test.osm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	conf := newConf()
+	p := New(f, conf)
+	header, err := p.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Generator != "go-osm test" || header.BBox[0] != 7.40 {
+		t.Errorf("unexpected header %#v", header)
+	}
+	// first node is not lost after reading the header
+	got := parseAll(t, p, conf)
+	if len(got.nodes) != 3 || got.nodes[0].ID != 1 {
+		t.Errorf("unexpected nodes %#v", got.nodes)
+	}
+}
+
+func TestParseInvalidRoot(t *testing.T) {
+	p := New(strings.NewReader(`<osmChange version="0.6"></osmChange>`), Config{})
+	if _, err := p.Header(); err == nil {
+		t.Fatal("expected error for invalid root element")
+	}
+	if err := p.Parse(context.Background()); err == nil || p.Error() == nil {
+		t.Error("expected error from Parse after invalid header")
+	}
+}
+
+// cancelReader calls cancel after limit bytes are read.
+type cancelReader struct {
+	r      io.Reader
+	limit  int
+	read   int
+	cancel func()
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if r.read < r.limit && r.read+n >= r.limit {
+		r.cancel()
+	}
+	r.read += n
+	return n, err
+}
+
+func TestParseCancel(t *testing.T) {
+	const node = `<node id="1" lat="1" lon="2"/>` + "\n"
+	doc := `<osm version="0.6">` + strings.Repeat(node, 3*batchSize) + `</osm>`
+	batchLen := batchSize * len(node)
+
+	ctx, stop := context.WithCancel(context.Background())
+	r := &cancelReader{
+		r: strings.NewReader(doc),
+		// in the middle of the second batch
+		limit:  batchLen + batchLen/2,
+		cancel: stop,
+	}
+	conf := newConf()
+	p := New(r, conf)
+
+	received := 0
+	done := make(chan struct{})
+	go func() {
+		for nds := range conf.Nodes {
+			received += len(nds)
+		}
+		close(done)
+	}()
+	go func() {
+		for range conf.Ways {
+		}
+	}()
+	go func() {
+		for range conf.Relations {
+		}
+	}()
+
+	if err := p.Parse(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	<-done
+	// only the first batch, the second batch is not sent after cancel
+	if received != batchSize {
+		t.Errorf("unexpected number of nodes %d", received)
+	}
+	// parsing stops with the next element, not with the next batch
+	if parsed := r.read - r.limit; parsed >= batchLen/2 {
+		t.Errorf("%d bytes read after cancel", parsed)
+	}
+}
+
+func TestParseCancelWithoutReceiver(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	stop()
+
+	conf := newConf()
+	p := New(strings.NewReader(`<osm version="0.6"><node id="1" lat="1" lon="2"/></osm>`), conf)
+	// nobody reads from the channels
+	if err := p.Parse(ctx); !errors.Is(err, context.Canceled) {
+		t.Error("expected context.Canceled, got", err)
+	}
+}
@@ -0,0 +1,181 @@
+package osmxml
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/bzip2"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+	"github.com/omniscale/go-osm/parser/internal/xmlelem"
+)
+
+type WriterConfig struct {
+	// Header specifies the generator and the bounds of the written file.
+	// Generator defaults to github.com/omniscale/go-osm. Version is
+	// always 0.6. The bounds element is only written if BBox is not zero.
+	Header Header
+
+	// IncludeMetadata indicates whether metadata like timestamps, versions
+	// and user names should be written.
+	IncludeMetadata bool
+}
+
+// ErrUnordered is returned if nodes are written after ways or relations,
+// or ways are written after relations.
+var ErrUnordered = errors.New("elements not ordered by type, expected nodes before ways before relations")
+
+type elemType int
+
+const (
+	noElem elemType = iota
+	nodeElem
+	wayElem
+	relationElem
+)
+
+// Writer encodes nodes, ways and relations into an OSM XML file.
+//
+// All nodes need to be written before all ways before all relations, as
+// expected by most tools (JOSM, osmium, osm2pgsql, etc.).
+type Writer struct {
+	w io.Writer
+	// compressor is closed after the end of the document is written
+	compressor    io.WriteCloser
+	compression   string
+	conf          WriterConfig
+	headerWritten bool
+	typ           elemType
+	buf           []byte
+	err           error
+}
+
+// NewWriter creates a new OSM XML writer for the provided output. Close
+// needs to be called after all elements are written. See NewGZIPWriter
+// and NewBZIP2Writer for compressed output.
+func NewWriter(w io.Writer, conf WriterConfig) *Writer {
+	return &Writer{w: w, conf: conf}
+}
+
+// NewGZIPWriter creates a new OSM XML writer for GZIP compressed output
+// (.osm.gz). Close flushes the GZIP stream.
+func NewGZIPWriter(w io.Writer, conf WriterConfig) *Writer {
+	gz := gzip.NewWriter(w)
+	return &Writer{w: gz, compressor: gz, compression: "gzip", conf: conf}
+}
+
+// NewBZIP2Writer creates a new OSM XML writer for BZIP2 compressed output
+// (.osm.bz2). Close flushes the BZIP2 stream.
+func NewBZIP2Writer(w io.Writer, conf WriterConfig) *Writer {
+	bz := bzip2.NewWriter(w)
+	return &Writer{w: bz, compressor: bz, compression: "bzip2", conf: conf}
+}
+
+// WriteNodes writes all nodes. The nodes are encoded immediately and can be
+// reused after WriteNodes returns.
+func (w *Writer) WriteNodes(nodes []osm.Node) error {
+	if err := w.prepare(nodeElem); err != nil {
+		return err
+	}
+	for i := range nodes {
+		w.buf = xmlelem.AppendNode(w.buf, "  ", &nodes[i], w.conf.IncludeMetadata)
+	}
+	return w.flush()
+}
+
+// WriteWays writes all ways. The ways are encoded immediately and can be
+// reused after WriteWays returns.
+func (w *Writer) WriteWays(ways []osm.Way) error {
+	if err := w.prepare(wayElem); err != nil {
+		return err
+	}
+	for i := range ways {
+		w.buf = xmlelem.AppendWay(w.buf, "  ", &ways[i], w.conf.IncludeMetadata)
+	}
+	return w.flush()
+}
+
+// WriteRelations writes all relations. The relations are encoded
+// immediately and can be reused after WriteRelations returns.
+func (w *Writer) WriteRelations(rels []osm.Relation) error {
+	if err := w.prepare(relationElem); err != nil {
+		return err
+	}
+	for i := range rels {
+		w.buf = xmlelem.AppendRelation(w.buf, "  ", &rels[i], w.conf.IncludeMetadata)
+	}
+	return w.flush()
+}
+
+// Close writes the end of the document. It does not close the underlying
+// io.Writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.prepare(noElem); err != nil {
+		return err
+	}
+	w.buf = append(w.buf, "</osm>\n"...)
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.compressor != nil {
+		if err := w.compressor.Close(); err != nil {
+			w.err = fmt.Errorf("closing %s writer: %w", w.compression, err)
+			return w.err
+		}
+	}
+	w.err = stream.ErrWriterClosed
+	return nil
+}
+
+// prepare writes the header and checks the order of the element types.
+func (w *Writer) prepare(t elemType) error {
+	if w.err != nil {
+		return w.err
+	}
+	if t != noElem {
+		if t < w.typ {
+			return ErrUnordered
+		}
+		w.typ = t
+	}
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+
+	header := w.conf.Header
+	if header.Generator == "" {
+		header.Generator = stream.DefaultGenerator
+	}
+	w.buf = append(w.buf, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"...)
+	w.buf = append(w.buf, `<osm version="0.6" generator="`...)
+	w.buf = xmlelem.AppendEscaped(w.buf, header.Generator)
+	w.buf = append(w.buf, "\">\n"...)
+	if header.BBox != [4]float64{} {
+		w.buf = append(w.buf, `  <bounds minlat="`...)
+		w.buf = strconv.AppendFloat(w.buf, header.BBox[1], 'f', -1, 64)
+		w.buf = append(w.buf, `" minlon="`...)
+		w.buf = strconv.AppendFloat(w.buf, header.BBox[0], 'f', -1, 64)
+		w.buf = append(w.buf, `" maxlat="`...)
+		w.buf = strconv.AppendFloat(w.buf, header.BBox[3], 'f', -1, 64)
+		w.buf = append(w.buf, `" maxlon="`...)
+		w.buf = strconv.AppendFloat(w.buf, header.BBox[2], 'f', -1, 64)
+		w.buf = append(w.buf, "\"/>\n"...)
+	}
+	return nil
+}
+
+func (w *Writer) flush() error {
+	if _, err := w.w.Write(w.buf); err != nil {
+		w.err = fmt.Errorf("writing elements: %w", err)
+		return w.err
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
@@ -0,0 +1,125 @@
+package osmxml
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		create func(io.Writer, WriterConfig) *Writer
+		open   func(io.Reader, Config) (*Parser, error)
+	}{
+		{name: "plain", create: NewWriter, open: func(r io.Reader, conf Config) (*Parser, error) { return New(r, conf), nil }},
+		{name: "gzip", create: NewGZIPWriter, open: NewGZIP},
+		{name: "bzip2", create: NewBZIP2Writer, open: NewBZIP2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.Open("test.osm")
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := newConf()
+			p := New(f, conf)
+			want := parseAll(t, p, conf)
+			header, _ := p.Header()
+			f.Close()
+
+			buf := &bytes.Buffer{}
+			w := tc.create(buf, WriterConfig{IncludeMetadata: true, Header: *header})
+			if err := w.WriteNodes(want.nodes); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteWays(want.ways); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteRelations(want.relations); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			conf = newConf()
+			p, err = tc.open(buf, conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := parseAll(t, p, conf)
+			gotHeader, _ := p.Header()
+
+			if *gotHeader != *header {
+				t.Errorf("unexpected header %#v", gotHeader)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("elements differ after round trip\n%#v\n%#v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriterOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{IncludeMetadata: true})
+	md := &osm.Metadata{Version: 1, Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Changeset: 100, UserID: 42, UserName: `"foo" & 'bar'`, Visible: true}
+	nodes := []osm.Node{
+		{Element: osm.Element{ID: 1, Metadata: md}, Lat: 43.73, Long: 7.41},
+		{Element: osm.Element{ID: 2, Tags: osm.Tags{"name": "<a>\n\x01", "amenity": "cafe"}}, Lat: -1, Long: 1.5},
+	}
+	ways := []osm.Way{{Element: osm.Element{ID: 10}, Refs: []int64{1, 2}}}
+	// metadata without timestamp
+	rels := []osm.Relation{{Element: osm.Element{ID: 20, Metadata: &osm.Metadata{Version: 2, Changeset: 5, Visible: true}}, Members: []osm.Member{{ID: 10, Type: osm.WayMember, Role: "outer"}}}}
+	w.WriteNodes(nodes)
+	w.WriteWays(ways)
+	w.WriteRelations(rels)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="github.com/omniscale/go-osm">
+  <node id="1" version="1" timestamp="2020-01-02T03:04:05Z" changeset="100" uid="42" user="&quot;foo&quot; &amp; &apos;bar&apos;" visible="true" lat="43.73" lon="7.41"/>
+  <node id="2" lat="-1" lon="1.5">
+    <tag k="amenity" v="cafe"/>
+    <tag k="name" v="&lt;a&gt;&#xA;` + "�" + `"/>
+  </node>
+  <way id="10">
+    <nd ref="1"/>
+    <nd ref="2"/>
+  </way>
+  <relation id="20" version="2" changeset="5" uid="0" user="" visible="true">
+    <member type="way" ref="10" role="outer"/>
+  </relation>
+</osm>
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriterOrder(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, WriterConfig{})
+	if err := w.WriteWays([]osm.Way{{Element: osm.Element{ID: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteNodes([]osm.Node{{Element: osm.Element{ID: 1}}}); err != ErrUnordered {
+		t.Error("expected ErrUnordered, got", err)
+	}
+	if err := w.WriteWays([]osm.Way{{Element: osm.Element{ID: 2}}}); err != nil {
+		t.Error(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRelations(nil); err != stream.ErrWriterClosed {
+		t.Error("expected error after Close, got", err)
+	}
+}
@@ -2,5 +2,8 @@
 Package pbf provides an efficient parser for OpenStreetMap PBF files.
 
 Files are parsed in parallel and nodes, ways, relations passed back in blocks via channels.
+Alternatively, a Handler can process each element directly from the parsing goroutines.
+
+The Writer creates new PBF files from nodes, ways and relations.
 */
 package pbf
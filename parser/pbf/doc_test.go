@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/omniscale/go-osm"
 	"github.com/omniscale/go-osm/parser/pbf"
@@ -84,3 +85,35 @@ func Example() {
 	fmt.Printf("parsed %d nodes, %d ways and %d relations\n", numNodes, numWays, numRelations)
 	// Output: parsed 17233 nodes, 2398 ways and 108 relations
 }
+
+func ExampleHandlerFuncs() {
+	f, err := os.Open("./monaco-20150428.osm.pbf")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	// The funcs are called concurrently, we need to count atomically.
+	var numNodes, numWays atomic.Int64
+
+	p := pbf.New(f, pbf.Config{
+		Handler: pbf.HandlerFuncs{
+			OnNode: func(nd *osm.Node) error {
+				numNodes.Add(1)
+				return nil
+			},
+			OnWay: func(w *osm.Way) error {
+				numWays.Add(1)
+				return nil
+			},
+			// Relations are not parsed, as OnRelation is nil.
+		},
+	})
+
+	if err := p.Parse(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("parsed %d nodes and %d ways\n", numNodes.Load(), numWays.Load())
+	// Output: parsed 17233 nodes and 2398 ways
+}
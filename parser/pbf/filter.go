@@ -0,0 +1,155 @@
+package pbf
+
+// Filter selects the elements that are parsed. Elements that do not match
+// are skipped before their tags, refs, members or metadata are decoded.
+type Filter struct {
+	// Types selects the element types that are parsed. All types are
+	// parsed if Types is 0.
+	Types ElementType
+
+	// Tags selects nodes, ways and relations with at least one matching
+	// tag. All elements are selected if Tags is empty. The Tags filter does
+	// not apply to the Coords channel, as coords are required to build the
+	// geometries of the selected ways.
+	Tags []TagFilter
+
+	// BBox selects nodes inside the bounding box (min lon, min lat, max
+	// lon, max lat), for the Coords and Nodes channel. The BBox filter is
+	// disabled if all values are 0. Ways and relations are not filtered by
+	// BBox.
+	BBox [4]float64
+}
+
+// TagFilter matches tags with Key and one of the Values. All values
+// match if Values is empty.
+type TagFilter struct {
+	Key    string
+	Values []string
+}
+
+func (f *Filter) includes(t ElementType) bool {
+	return f.Types == 0 || f.Types&t != 0
+}
+
+func (f *Filter) contains(lat, long float64) bool {
+	if f.BBox == [4]float64{} {
+		return true
+	}
+	return long >= f.BBox[0] && lat >= f.BBox[1] && long <= f.BBox[2] && lat <= f.BBox[3]
+}
+
+// tagRef references the key (value == -1) or a value of a TagFilter.
+type tagRef struct {
+	filter int
+	value  int
+}
+
+// newTagRefs returns all strings of the tag filters and their references.
+func newTagRefs(filters []TagFilter) map[string][]tagRef {
+	if len(filters) == 0 {
+		return nil
+	}
+	refs := make(map[string][]tagRef)
+	for i, f := range filters {
+		refs[f.Key] = append(refs[f.Key], tagRef{filter: i, value: -1})
+		for j, v := range f.Values {
+			refs[v] = append(refs[v], tagRef{filter: i, value: j})
+		}
+	}
+	return refs
+}
+
+// tagMatcher matches the raw key and value indices of a single block
+// against the TagFilters. A nil tagMatcher matches all tags.
+type tagMatcher struct {
+	// keys contains the string index of each filter key, or -1 if the key
+	// is not in the string table
+	keys []int64
+	// values contains the string indices of all values of each filter.
+	// values is nil for filters without values (any value).
+	values []map[uint32]struct{}
+}
+
+// reset resolves the filter strings to the indices of stringtable.
+func (m *tagMatcher) reset(filters []TagFilter, refs map[string][]tagRef, stringtable stringTable) {
+	if cap(m.keys) < len(filters) {
+		m.keys = make([]int64, len(filters))
+		m.values = make([]map[uint32]struct{}, len(filters))
+	}
+	m.keys = m.keys[:len(filters)]
+	m.values = m.values[:len(filters)]
+	for i, f := range filters {
+		m.keys[i] = -1
+		if len(f.Values) == 0 {
+			m.values[i] = nil
+		} else if m.values[i] == nil {
+			m.values[i] = make(map[uint32]struct{}, len(f.Values))
+		} else {
+			clear(m.values[i])
+		}
+	}
+	for i, s := range stringtable {
+		for _, ref := range refs[s] {
+			if ref.value == -1 {
+				m.keys[ref.filter] = int64(i)
+			} else {
+				m.values[ref.filter][uint32(i)] = struct{}{}
+			}
+		}
+	}
+}
+
+func (m *tagMatcher) matchTag(key, val uint32) bool {
+	for i, k := range m.keys {
+		if k != int64(key) {
+			continue
+		}
+		if m.values[i] == nil {
+			return true
+		}
+		if _, ok := m.values[i][val]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns whether one of the tags matches.
+func (m *tagMatcher) match(keys, vals []uint32) bool {
+	if m == nil {
+		return true
+	}
+	for i := range keys {
+		if m.matchTag(keys[i], vals[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDense returns whether one of the tags of the next dense node
+// matches. pos is the position of the first key in keysVals.
+func (m *tagMatcher) matchDense(keysVals []int32, pos int) bool {
+	if m == nil {
+		return true
+	}
+	for pos+1 < len(keysVals) && keysVals[pos] != 0 {
+		if m.matchTag(uint32(keysVals[pos]), uint32(keysVals[pos+1])) {
+			return true
+		}
+		pos += 2
+	}
+	return false
+}
+
+// skipDenseTags returns the position after the tags of the next dense
+// node.
+func skipDenseTags(keysVals []int32, pos int) int {
+	for pos < len(keysVals) {
+		if keysVals[pos] == 0 {
+			return pos + 1
+		}
+		pos += 2
+	}
+	return pos
+}
@@ -0,0 +1,183 @@
+package pbf
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/omniscale/go-osm"
+)
+
+func parseFiltered(t *testing.T, filter Filter, withCoords bool) (coords, nodes []osm.Node, ways []osm.Way, rels []osm.Relation) {
+	t.Helper()
+	f, err := os.Open("./monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	conf := Config{
+		Filter:      filter,
+		Nodes:       make(chan []osm.Node),
+		Ways:        make(chan []osm.Way),
+		Relations:   make(chan []osm.Relation),
+		Concurrency: 1,
+	}
+	if withCoords {
+		conf.Coords = make(chan []osm.Node)
+	}
+	p := New(f, conf)
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	go func() {
+		if conf.Coords != nil {
+			for nds := range conf.Coords {
+				coords = append(coords, nds...)
+			}
+		}
+		wg.Done()
+	}()
+	go func() {
+		for nds := range conf.Nodes {
+			nodes = append(nodes, nds...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for ws := range conf.Ways {
+			ways = append(ways, ws...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for rs := range conf.Relations {
+			rels = append(rels, rs...)
+		}
+		wg.Done()
+	}()
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	return coords, nodes, ways, rels
+}
+
+func TestFilterTypes(t *testing.T) {
+	coords, nodes, ways, rels := parseFiltered(t, Filter{Types: WayType | RelationType}, true)
+	if len(coords) != 0 || len(nodes) != 0 || len(ways) != 2398 || len(rels) != 108 {
+		t.Error("unexpected number of elements", len(coords), len(nodes), len(ways), len(rels))
+	}
+}
+
+func TestFilterTags(t *testing.T) {
+	_, allNodes, allWays, allRels := parseFiltered(t, Filter{}, true)
+
+	hasTag := func(tags osm.Tags, key string, values ...string) bool {
+		v, ok := tags[key]
+		if !ok {
+			return false
+		}
+		if len(values) == 0 {
+			return true
+		}
+		for _, value := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+
+	filter := Filter{Tags: []TagFilter{
+		{Key: "highway"},
+		{Key: "amenity", Values: []string{"restaurant", "cafe"}},
+		{Key: "type", Values: []string{"route"}},
+	}}
+	match := func(tags osm.Tags) bool {
+		return hasTag(tags, "highway") || hasTag(tags, "amenity", "restaurant", "cafe") || hasTag(tags, "type", "route")
+	}
+
+	var wantNodes []osm.Node
+	for _, nd := range allNodes {
+		if match(nd.Tags) {
+			wantNodes = append(wantNodes, nd)
+		}
+	}
+	var wantWays []osm.Way
+	for _, w := range allWays {
+		if match(w.Tags) {
+			wantWays = append(wantWays, w)
+		}
+	}
+	var wantRels []osm.Relation
+	for _, r := range allRels {
+		if match(r.Tags) {
+			wantRels = append(wantRels, r)
+		}
+	}
+	if len(wantNodes) == 0 || len(wantWays) == 0 || len(wantRels) == 0 || len(wantWays) == len(allWays) {
+		t.Fatal("filter does not select a subset", len(wantNodes), len(wantWays), len(wantRels))
+	}
+
+	coords, nodes, ways, rels := parseFiltered(t, filter, true)
+	if len(coords) != 17233 {
+		t.Error("coords should not be filtered by tags", len(coords))
+	}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("unexpected nodes, got %d, want %d", len(nodes), len(wantNodes))
+	}
+	if !reflect.DeepEqual(ways, wantWays) {
+		t.Errorf("unexpected ways, got %d, want %d", len(ways), len(wantWays))
+	}
+	if !reflect.DeepEqual(rels, wantRels) {
+		t.Errorf("unexpected relations, got %d, want %d", len(rels), len(wantRels))
+	}
+
+	// without Coords, all nodes are sent to Nodes
+	_, nodes, _, _ = parseFiltered(t, filter, false)
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("unexpected nodes without coords, got %d, want %d", len(nodes), len(wantNodes))
+	}
+
+	_, nodes, ways, rels = parseFiltered(t, Filter{Tags: []TagFilter{{Key: "no-such-key"}}}, true)
+	if len(nodes) != 0 || len(ways) != 0 || len(rels) != 0 {
+		t.Error("unexpected elements", len(nodes), len(ways), len(rels))
+	}
+}
+
+func TestFilterBBox(t *testing.T) {
+	bbox := [4]float64{7.42, 43.73, 7.43, 43.74}
+	allCoords, allNodes, _, _ := parseFiltered(t, Filter{}, true)
+
+	inside := func(nd osm.Node) bool {
+		return nd.Long >= bbox[0] && nd.Lat >= bbox[1] && nd.Long <= bbox[2] && nd.Lat <= bbox[3]
+	}
+	var wantCoords, wantNodes []osm.Node
+	for _, nd := range allCoords {
+		if inside(nd) {
+			wantCoords = append(wantCoords, nd)
+		}
+	}
+	for _, nd := range allNodes {
+		if inside(nd) {
+			wantNodes = append(wantNodes, nd)
+		}
+	}
+	if len(wantCoords) == 0 || len(wantCoords) == len(allCoords) || len(wantNodes) == 0 {
+		t.Fatal("bbox does not select a subset", len(wantCoords), len(wantNodes))
+	}
+
+	coords, nodes, ways, _ := parseFiltered(t, Filter{BBox: bbox}, true)
+	if !reflect.DeepEqual(coords, wantCoords) {
+		t.Errorf("unexpected coords, got %d, want %d", len(coords), len(wantCoords))
+	}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("unexpected nodes, got %d, want %d", len(nodes), len(wantNodes))
+	}
+	if len(ways) != 2398 {
+		t.Error("ways should not be filtered by bbox", len(ways))
+	}
+}
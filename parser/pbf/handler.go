@@ -0,0 +1,73 @@
+package pbf
+
+import (
+	"github.com/omniscale/go-osm"
+)
+
+// Handler receives the parsed elements as an alternative to the channels
+// of the Config. The methods are called concurrently from all decoding
+// goroutines, unless Config.Ordered is set. Parsing is aborted if a method
+// returns an error and Parse returns this error.
+//
+// The elements are only valid during the call if Config.RecycleBatches
+// is enabled. Copy all values you need to keep.
+type Handler interface {
+	// Node is called for each node that would be sent to Config.Nodes,
+	// i.e. for all nodes if Config.Coords is nil, otherwise only for nodes
+	// with tags.
+	Node(*osm.Node) error
+	Way(*osm.Way) error
+	Relation(*osm.Relation) error
+}
+
+// HandlerFuncs is a Handler that calls the non-nil funcs. The parser does
+// not decode element types with a nil func, unless they are required by
+// the channels of the Config.
+type HandlerFuncs struct {
+	OnNode     func(*osm.Node) error
+	OnWay      func(*osm.Way) error
+	OnRelation func(*osm.Relation) error
+}
+
+func (h HandlerFuncs) Node(nd *osm.Node) error {
+	if h.OnNode == nil {
+		return nil
+	}
+	return h.OnNode(nd)
+}
+
+func (h HandlerFuncs) Way(w *osm.Way) error {
+	if h.OnWay == nil {
+		return nil
+	}
+	return h.OnWay(w)
+}
+
+func (h HandlerFuncs) Relation(r *osm.Relation) error {
+	if h.OnRelation == nil {
+		return nil
+	}
+	return h.OnRelation(r)
+}
+
+// handles returns the element types that h needs.
+func handles(h Handler) ElementType {
+	if h == nil {
+		return 0
+	}
+	funcs, ok := h.(HandlerFuncs)
+	if !ok {
+		return NodeType | WayType | RelationType
+	}
+	var types ElementType
+	if funcs.OnNode != nil {
+		types |= NodeType
+	}
+	if funcs.OnWay != nil {
+		types |= WayType
+	}
+	if funcs.OnRelation != nil {
+		types |= RelationType
+	}
+	return types
+}
@@ -0,0 +1,398 @@
+package pbf
+
+import (
+	"bufio"
+	structs "encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ElementType specifies one or more types of OSM elements.
+type ElementType uint8
+
+const (
+	NodeType ElementType = 1 << iota
+	WayType
+	RelationType
+)
+
+// An IndexEntry describes a single data block of a PBF file.
+type IndexEntry struct {
+	// Index of the block in the file. The header block has index 0, the
+	// first data block index 1.
+	Index int
+	// Offset of the block in the file in bytes.
+	Offset int64
+	// Size of the block in bytes, including the BlobHeader. Parsers
+	// created with OpenIndexed return a BlockError if the size of the
+	// block in the file differs, e.g. for an outdated index.
+	Size int64
+	// Types of all elements in this block.
+	Types ElementType
+	// MinID and MaxID of all elements in this block. IDs of different
+	// element types are combined if a block contains more then one type.
+	MinID int64
+	MaxID int64
+}
+
+// An Index contains the position, element type and ID range of all data
+// blocks of a PBF file.
+type Index struct {
+	Entries []IndexEntry
+}
+
+// IndexFilter selects data blocks from an Index.
+type IndexFilter struct {
+	// Types selects all blocks that contain at least one of these types.
+	// All types are selected if Types is 0.
+	Types ElementType
+	// MinID and MaxID selects all blocks with elements in this ID range.
+	// Both values are inclusive, a value of 0 disables the limit.
+	MinID int64
+	MaxID int64
+}
+
+func (f IndexFilter) match(e IndexEntry) bool {
+	if f.Types != 0 && e.Types&f.Types == 0 {
+		return false
+	}
+	if f.MinID != 0 && e.MaxID < f.MinID {
+		return false
+	}
+	if f.MaxID != 0 && e.MinID > f.MaxID {
+		return false
+	}
+	return true
+}
+
+// Filter returns all entries that match the filter.
+func (idx *Index) Filter(filter IndexFilter) []IndexEntry {
+	var result []IndexEntry
+	for _, e := range idx.Entries {
+		if filter.match(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// BuildIndex creates a new Index for the PBF file. It first scans all
+// BlobHeaders, skipping the actual data with Seek. Each data block is then
+// uncompressed to collect the element types and IDs. Only the ID fields
+// are decoded, string tables, tags, refs and metadata are skipped.
+//
+// BuildIndex reads and uncompresses the whole file in a single goroutine.
+// The cost is similar to parsing the file with Concurrency 1 and without
+// any destination. Write the index with WriteFile and reuse it with
+// ReadIndexFile if the file is opened multiple times.
+func BuildIndex(r io.ReadSeeker) (*Index, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to start: %w", err)
+	}
+	cr := &countingReader{r: r}
+
+	idx := &Index{}
+	for i := 0; ; i++ {
+		offset := cr.n
+		header, err := nextBlobHeader(cr)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &BlockError{Index: i, Offset: offset, Err: err}
+		}
+		size := int64(header.GetDatasize())
+		if i == 0 && header.GetType() != "OSMHeader" {
+			return nil, errors.New("invalid block type, expected OSMHeader, got " + header.GetType())
+		}
+		if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+			return nil, &BlockError{Index: i, Offset: offset, Err: fmt.Errorf("skipping block: %w", err)}
+		}
+		cr.n += size
+		if header.GetType() != "OSMData" {
+			continue
+		}
+		idx.Entries = append(idx.Entries, IndexEntry{
+			Index:  i,
+			Offset: offset,
+			Size:   cr.n - offset,
+		})
+	}
+
+	for i := range idx.Entries {
+		e := &idx.Entries[i]
+		if _, err := r.Seek(e.Offset, io.SeekStart); err != nil {
+			return nil, &BlockError{Index: e.Index, Offset: e.Offset, Err: fmt.Errorf("seeking to block: %w", err)}
+		}
+		_, data, err := nextBlock(r)
+		if err != nil {
+			return nil, &BlockError{Index: e.Index, Offset: e.Offset, Err: err}
+		}
+		block, err := decodeRawBlob(data)
+		putBuf(data)
+		if err != nil {
+			return nil, &BlockError{Index: e.Index, Offset: e.Offset, Err: fmt.Errorf("decoding raw blob: %w", err)}
+		}
+		e.Types, e.MinID, e.MaxID, err = blockIDRange(block)
+		putBuf(block)
+		if err != nil {
+			return nil, &BlockError{Index: e.Index, Offset: e.Offset, Err: err}
+		}
+	}
+	return idx, nil
+}
+
+// blockIDRange returns the element types and min/max ID of all elements in
+// the encoded PrimitiveBlock. It only decodes the ID fields of the
+// elements and skips all other fields.
+func blockIDRange(block []byte) (types ElementType, minID, maxID int64, err error) {
+	first := true
+	add := func(t ElementType, id int64) {
+		types |= t
+		if first || id < minID {
+			minID = id
+		}
+		if first || id > maxID {
+			maxID = id
+		}
+		first = false
+	}
+	err = forEachField(block, func(num, typ int, _ uint64, group []byte) error {
+		if num != 2 || typ != wireBytes { // primitivegroup
+			return nil
+		}
+		return forEachField(group, func(num, typ int, _ uint64, elem []byte) error {
+			if typ != wireBytes {
+				return nil
+			}
+			switch num {
+			case 1: // nodes
+				id, err := messageID(elem)
+				add(NodeType, zigzag(id))
+				return err
+			case 2: // dense
+				return forEachField(elem, func(num, typ int, _ uint64, ids []byte) error {
+					if num != 1 || typ != wireBytes {
+						return nil
+					}
+					var id int64
+					for len(ids) > 0 {
+						delta, n := structs.Uvarint(ids)
+						if n <= 0 {
+							return errInvalidWireFormat
+						}
+						ids = ids[n:]
+						id += zigzag(delta)
+						add(NodeType, id)
+					}
+					return nil
+				})
+			case 3: // ways
+				id, err := messageID(elem)
+				add(WayType, int64(id))
+				return err
+			case 4: // relations
+				id, err := messageID(elem)
+				add(RelationType, int64(id))
+				return err
+			}
+			return nil
+		})
+	})
+	return types, minID, maxID, err
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errInvalidWireFormat = errors.New("invalid protobuf wire format")
+
+// forEachField calls fn for each field of the encoded protobuf message.
+// fn receives the value of varint fields or the data of length-delimited
+// fields.
+func forEachField(msg []byte, fn func(num, typ int, v uint64, data []byte) error) error {
+	for len(msg) > 0 {
+		key, n := structs.Uvarint(msg)
+		if n <= 0 {
+			return errInvalidWireFormat
+		}
+		msg = msg[n:]
+		num, typ := int(key>>3), int(key&7)
+		var v uint64
+		var data []byte
+		switch typ {
+		case wireVarint:
+			v, n = structs.Uvarint(msg)
+			if n <= 0 {
+				return errInvalidWireFormat
+			}
+			msg = msg[n:]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if typ == wireFixed32 {
+				size = 4
+			}
+			if len(msg) < size {
+				return errInvalidWireFormat
+			}
+			msg = msg[size:]
+		case wireBytes:
+			l, n := structs.Uvarint(msg)
+			if n <= 0 || l > uint64(len(msg)-n) {
+				return errInvalidWireFormat
+			}
+			data = msg[n : n+int(l)]
+			msg = msg[n+int(l):]
+		default:
+			return errInvalidWireFormat
+		}
+		if err := fn(num, typ, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageID returns the raw value of the id field (1) of a Node, Way or
+// Relation message.
+func messageID(msg []byte) (uint64, error) {
+	var id uint64
+	err := forEachField(msg, func(num, typ int, v uint64, _ []byte) error {
+		if num == 1 && typ == wireVarint {
+			id = v
+		}
+		return nil
+	})
+	return id, err
+}
+
+func zigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+const indexMagic = "GOOSMPBFIDX1"
+
+type indexRecord struct {
+	Index  int32
+	Types  uint8
+	Offset int64
+	Size   int64
+	MinID  int64
+	MaxID  int64
+}
+
+// WriteTo writes the index in a binary format that can be read with
+// ReadIndex.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	n, err := bw.WriteString(indexMagic)
+	written := int64(n)
+	if err != nil {
+		return written, err
+	}
+	if err := structs.Write(bw, structs.BigEndian, int64(len(idx.Entries))); err != nil {
+		return written, err
+	}
+	written += 8
+	for _, e := range idx.Entries {
+		rec := indexRecord{
+			Index:  int32(e.Index),
+			Types:  uint8(e.Types),
+			Offset: e.Offset,
+			Size:   e.Size,
+			MinID:  e.MinID,
+			MaxID:  e.MaxID,
+		}
+		if err := structs.Write(bw, structs.BigEndian, &rec); err != nil {
+			return written, err
+		}
+		written += int64(structs.Size(&rec))
+	}
+	return written, bw.Flush()
+}
+
+// WriteFile writes the index to filename, e.g. a sidecar file next to the
+// PBF file.
+func (idx *Index) WriteFile(filename string) error {
+	tmpname := filename + "~"
+	f, err := os.Create(tmpname)
+	if err != nil {
+		return fmt.Errorf("creating temp file for writing index: %w", err)
+	}
+	_, err = idx.WriteTo(f)
+	if err != nil {
+		f.Close()
+		os.Remove(tmpname)
+		return fmt.Errorf("writing index to %q: %w", tmpname, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpname)
+		return fmt.Errorf("writing index to %q: %w", tmpname, err)
+	}
+	return os.Rename(tmpname, filename)
+}
+
+// ReadIndex reads an index written with Index.WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, fmt.Errorf("reading index header: %w", err)
+	}
+	if string(magic) != indexMagic {
+		return nil, errors.New("not a PBF index file")
+	}
+	var num int64
+	if err := structs.Read(br, structs.BigEndian, &num); err != nil {
+		return nil, fmt.Errorf("reading index header: %w", err)
+	}
+	if num < 0 {
+		return nil, fmt.Errorf("invalid number of index entries %d", num)
+	}
+	idx := &Index{}
+	for i := int64(0); i < num; i++ {
+		rec := indexRecord{}
+		if err := structs.Read(br, structs.BigEndian, &rec); err != nil {
+			return nil, fmt.Errorf("reading index entry: %w", err)
+		}
+		idx.Entries = append(idx.Entries, IndexEntry{
+			Index:  int(rec.Index),
+			Types:  ElementType(rec.Types),
+			Offset: rec.Offset,
+			Size:   rec.Size,
+			MinID:  rec.MinID,
+			MaxID:  rec.MaxID,
+		})
+	}
+	return idx, nil
+}
+
+// ReadIndexFile reads an index from filename.
+func ReadIndexFile(filename string) (*Index, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadIndex(f)
+}
+
+// OpenIndexed creates a new PBF parser that only parses the data blocks
+// from idx that match the filter. Note that all elements from the matching
+// blocks are parsed, including elements outside of the ID range and
+// elements of other types if their destination is set in Config.
+func OpenIndexed(r io.ReadSeeker, idx *Index, filter IndexFilter, conf Config) (*Parser, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to start: %w", err)
+	}
+	p := New(r, conf)
+	p.seeker = r
+	p.entries = idx.Filter(filter)
+	return p, nil
+}
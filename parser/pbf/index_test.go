@@ -0,0 +1,249 @@
+package pbf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/omniscale/go-osm"
+)
+
+func TestBuildIndex(t *testing.T) {
+	f, err := os.Open("./monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	idx, err := BuildIndex(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(idx.Entries); n != 3 {
+		t.Fatal("unexpected number of entries", n, idx.Entries)
+	}
+	for i, want := range []IndexEntry{
+		{Index: 1, Offset: 138, Types: NodeType},
+		{Index: 2, Types: NodeType},
+		{Index: 3, Types: NodeType | WayType | RelationType},
+	} {
+		got := idx.Entries[i]
+		if got.Index != want.Index || got.Types != want.Types || (want.Offset != 0 && got.Offset != want.Offset) {
+			t.Errorf("unexpected entry %d: %#v", i, got)
+		}
+		if got.MinID > got.MaxID {
+			t.Errorf("invalid ID range for entry %d: %#v", i, got)
+		}
+		if i > 0 && idx.Entries[i-1].Offset+idx.Entries[i-1].Size != got.Offset {
+			t.Errorf("entry %d does not start after previous entry", i)
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "monaco.idx")
+	if err := idx.WriteFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadIndexFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, idx) {
+		t.Errorf("index differs after reading:\n%#v\n%#v", got, idx)
+	}
+
+	if _, err := ReadIndex(bytes.NewBufferString("foo")); err == nil {
+		t.Error("expected error for invalid index")
+	}
+}
+
+func TestOpenIndexed(t *testing.T) {
+	// rewrite monaco, so that each block only contains a single type
+	mf, err := os.Open("./monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	monaco := parseAll(t, mf, false)
+	mf.Close()
+
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{})
+	w.WriteNodes(monaco.nodes)
+	w.WriteWays(monaco.ways)
+	w.WriteRelations(monaco.relations)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f := bytes.NewReader(buf.Bytes())
+
+	idx, err := BuildIndex(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(idx.Entries); n != 5 {
+		t.Fatal("unexpected number of entries", n, idx.Entries)
+	}
+
+	parse := func(filter IndexFilter) (nodes, ways, rels []int64) {
+		conf := Config{
+			Nodes:     make(chan []osm.Node),
+			Ways:      make(chan []osm.Way),
+			Relations: make(chan []osm.Relation),
+		}
+		p, err := OpenIndexed(f, idx, filter, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg := sync.WaitGroup{}
+		wg.Add(3)
+		go func() {
+			for nds := range conf.Nodes {
+				for _, nd := range nds {
+					nodes = append(nodes, nd.ID)
+				}
+			}
+			wg.Done()
+		}()
+		go func() {
+			for ws := range conf.Ways {
+				for _, w := range ws {
+					ways = append(ways, w.ID)
+				}
+			}
+			wg.Done()
+		}()
+		go func() {
+			for rs := range conf.Relations {
+				for _, r := range rs {
+					rels = append(rels, r.ID)
+				}
+			}
+			wg.Done()
+		}()
+		if err := p.Parse(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		if _, err := p.Header(); err != nil {
+			t.Fatal(err)
+		}
+		return nodes, ways, rels
+	}
+
+	nodes, ways, rels := parse(IndexFilter{Types: WayType})
+	if len(nodes) != 0 || len(ways) != 2398 || len(rels) != 0 {
+		t.Error("unexpected number of elements", len(nodes), len(ways), len(rels))
+	}
+
+	second := idx.Entries[1]
+	nodes, ways, rels = parse(IndexFilter{Types: NodeType, MinID: second.MinID, MaxID: second.MaxID})
+	if len(nodes) != 8000 || len(ways) != 0 || len(rels) != 0 {
+		t.Error("unexpected number of elements", len(nodes), len(ways), len(rels))
+	}
+	for _, id := range nodes {
+		if id < second.MinID || id > second.MaxID {
+			t.Error("node outside of ID range", id)
+		}
+	}
+
+	nodes, ways, rels = parse(IndexFilter{MinID: 1 << 40})
+	if len(nodes) != 0 || len(ways) != 0 || len(rels) != 0 {
+		t.Error("unexpected number of elements", len(nodes), len(ways), len(rels))
+	}
+}
+
+func TestBlockIDRange(t *testing.T) {
+	f, err := os.Open("./monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := parseHeader(f); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, data, err := nextBlock(f)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := decodeRawBlob(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types, minID, maxID, err := blockIDRange(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// compare with IDs from fully decoded block
+		block, err := decodePrimitiveBlock(data, &blockBuf{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wantTypes ElementType
+		var ids []int64
+		for _, group := range block.Primitivegroup {
+			if dense := group.GetDense(); dense != nil {
+				var id int64
+				for _, delta := range dense.Id {
+					id += delta
+					ids = append(ids, id)
+					wantTypes |= NodeType
+				}
+			}
+			for _, w := range group.Ways {
+				ids = append(ids, w.Id)
+				wantTypes |= WayType
+			}
+			for _, r := range group.Relations {
+				ids = append(ids, r.Id)
+				wantTypes |= RelationType
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		if types != wantTypes || minID != ids[0] || maxID != ids[len(ids)-1] {
+			t.Errorf("unexpected range %d %d-%d, want %d %d-%d", types, minID, maxID, wantTypes, ids[0], ids[len(ids)-1])
+		}
+	}
+
+	if _, _, _, err := blockIDRange([]byte{0x12, 0xff}); err != errInvalidWireFormat {
+		t.Error("expected errInvalidWireFormat, got", err)
+	}
+}
+
+func TestOpenIndexedOutdated(t *testing.T) {
+	f, err := os.Open("./monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	idx, err := BuildIndex(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Entries[1].Size += 1
+
+	conf := Config{Nodes: make(chan []osm.Node)}
+	go func() {
+		for range conf.Nodes {
+		}
+	}()
+	p, err := OpenIndexed(f, idx, IndexFilter{Types: NodeType}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bErr *BlockError
+	if err := p.Parse(context.Background()); !errors.As(err, &bErr) || bErr.Index != 2 {
+		t.Error("expected BlockError for second block, got", err)
+	}
+}
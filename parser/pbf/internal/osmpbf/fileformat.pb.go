@@ -39,6 +39,10 @@ type Blob struct {
 	LzmaData []byte `protobuf:"bytes,4,opt,name=lzma_data,json=lzmaData" json:"lzma_data"`
 	// Formerly used for bzip2 compressed data. Depreciated in 2010.
 	OBSOLETEBzip2Data []byte `protobuf:"bytes,5,opt,name=OBSOLETE_bzip2_data,json=OBSOLETEBzip2Data" json:"OBSOLETE_bzip2_data"`
+	// LZ4 block compressed data.
+	Lz4Data []byte `protobuf:"bytes,6,opt,name=lz4_data,json=lz4Data" json:"lz4_data"`
+	// Zstandard compressed data.
+	ZstdData []byte `protobuf:"bytes,7,opt,name=zstd_data,json=zstdData" json:"zstd_data"`
 }
 
 func (m *Blob) Reset()                    { *m = Blob{} }
@@ -81,6 +85,20 @@ func (m *Blob) GetOBSOLETEBzip2Data() []byte {
 	return nil
 }
 
+func (m *Blob) GetLz4Data() []byte {
+	if m != nil {
+		return m.Lz4Data
+	}
+	return nil
+}
+
+func (m *Blob) GetZstdData() []byte {
+	if m != nil {
+		return m.ZstdData
+	}
+	return nil
+}
+
 type BlobHeader struct {
 	Type      string `protobuf:"bytes,1,req,name=type" json:"type"`
 	Indexdata []byte `protobuf:"bytes,2,opt,name=indexdata" json:"indexdata"`
@@ -159,6 +177,18 @@ func (m *Blob) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintFileformat(dAtA, i, uint64(len(m.OBSOLETEBzip2Data)))
 		i += copy(dAtA[i:], m.OBSOLETEBzip2Data)
 	}
+	if m.Lz4Data != nil {
+		dAtA[i] = 0x32
+		i++
+		i = encodeVarintFileformat(dAtA, i, uint64(len(m.Lz4Data)))
+		i += copy(dAtA[i:], m.Lz4Data)
+	}
+	if m.ZstdData != nil {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintFileformat(dAtA, i, uint64(len(m.ZstdData)))
+		i += copy(dAtA[i:], m.ZstdData)
+	}
 	return i, nil
 }
 
@@ -222,6 +252,14 @@ func (m *Blob) Size() (n int) {
 		l = len(m.OBSOLETEBzip2Data)
 		n += 1 + l + sovFileformat(uint64(l))
 	}
+	if m.Lz4Data != nil {
+		l = len(m.Lz4Data)
+		n += 1 + l + sovFileformat(uint64(l))
+	}
+	if m.ZstdData != nil {
+		l = len(m.ZstdData)
+		n += 1 + l + sovFileformat(uint64(l))
+	}
 	return n
 }
 
@@ -423,6 +461,68 @@ func (m *Blob) Unmarshal(dAtA []byte) error {
 				m.OBSOLETEBzip2Data = []byte{}
 			}
 			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Lz4Data", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileformat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthFileformat
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Lz4Data = append(m.Lz4Data[:0], dAtA[iNdEx:postIndex]...)
+			if m.Lz4Data == nil {
+				m.Lz4Data = []byte{}
+			}
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ZstdData", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileformat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthFileformat
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ZstdData = append(m.ZstdData[:0], dAtA[iNdEx:postIndex]...)
+			if m.ZstdData == nil {
+				m.ZstdData = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFileformat(dAtA[iNdEx:])
@@ -692,25 +792,26 @@ func init() {
 }
 
 var fileDescriptorFileformat = []byte{
-	// 294 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x8f, 0xb1, 0x6a, 0xeb, 0x30,
-	0x14, 0x86, 0x23, 0xdb, 0xc9, 0x4d, 0x0e, 0xb7, 0x43, 0x55, 0x28, 0x9a, 0x1c, 0x27, 0x53, 0xa0,
-	0x90, 0x40, 0x87, 0x3e, 0x80, 0x69, 0x20, 0x43, 0x21, 0x90, 0x74, 0x0f, 0x47, 0x8d, 0x0c, 0x02,
-	0xd9, 0x32, 0xb2, 0xc0, 0xb5, 0x9f, 0xa2, 0x2f, 0xd4, 0x3d, 0x63, 0x9f, 0xa0, 0x14, 0xf7, 0x45,
-	0x8a, 0xec, 0x34, 0xf5, 0x24, 0xf4, 0x9d, 0xef, 0x48, 0xff, 0x0f, 0x77, 0x39, 0x9a, 0x42, 0x98,
-	0x55, 0xce, 0x93, 0x95, 0xcc, 0xac, 0x30, 0x19, 0xaa, 0x95, 0x2e, 0x52, 0x77, 0x4f, 0xa4, 0x12,
-	0x89, 0x36, 0x29, 0xda, 0x65, 0x6e, 0xb4, 0xd5, 0x74, 0xd4, 0x0d, 0xe6, 0xef, 0x04, 0x82, 0x58,
-	0x69, 0x4e, 0x6f, 0xc1, 0x37, 0x58, 0x32, 0x12, 0x91, 0xc5, 0xff, 0x38, 0x38, 0x7d, 0x4e, 0x07,
-	0x3b, 0x07, 0xe8, 0x14, 0xc6, 0x06, 0xcb, 0x43, 0x21, 0x6b, 0xc1, 0xbc, 0x88, 0x2c, 0x86, 0xe7,
-	0xe1, 0x3f, 0x83, 0xe5, 0x5e, 0xd6, 0x82, 0xce, 0x60, 0x52, 0x2b, 0xc9, 0x0f, 0x47, 0xb4, 0xc8,
-	0xfc, 0xde, 0xfa, 0xd8, 0xe1, 0x47, 0xb4, 0xe8, 0x14, 0x55, 0xa7, 0xd8, 0x29, 0x41, 0x5f, 0x71,
-	0xb8, 0x55, 0x1e, 0xe0, 0x66, 0x1b, 0xef, 0xb7, 0x4f, 0xeb, 0xe7, 0xf5, 0x81, 0xd7, 0x32, 0xbf,
-	0xef, 0xe4, 0x61, 0x2b, 0x8f, 0x9c, 0xcc, 0xc8, 0xee, 0xfa, 0x57, 0x89, 0x9d, 0xe1, 0xf6, 0xe6,
-	0x0a, 0xc0, 0xc5, 0xdf, 0x08, 0x3c, 0x0a, 0x43, 0x19, 0x04, 0xb6, 0xca, 0x05, 0x23, 0x91, 0xb7,
-	0x98, 0x9c, 0xff, 0x68, 0x09, 0x9d, 0xc3, 0x44, 0x66, 0x47, 0xf1, 0xda, 0xbe, 0xea, 0xf5, 0x22,
-	0xfc, 0x61, 0x1a, 0xc1, 0xd8, 0x9d, 0x6d, 0x55, 0x3f, 0xf2, 0x2e, 0x55, 0x2f, 0x34, 0x9e, 0x9d,
-	0x9a, 0x90, 0x7c, 0x34, 0x21, 0xf9, 0x6a, 0x42, 0xf2, 0xf6, 0x1d, 0x0e, 0xe0, 0xea, 0xc5, 0xe8,
-	0x82, 0x57, 0x4b, 0x2e, 0x33, 0x34, 0xd5, 0xc6, 0xff, 0x09, 0x00, 0x00, 0xff, 0xff, 0xac, 0xea,
-	0xf3, 0xa9, 0x86, 0x01, 0x00, 0x00,
+	// 316 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x90, 0x41, 0x6a, 0xc2, 0x40,
+	0x14, 0x40, 0x9d, 0x24, 0x6a, 0xf2, 0x69, 0x17, 0x9d, 0x42, 0x49, 0x29, 0xc4, 0xe8, 0x4a, 0x28,
+	0x28, 0x14, 0xe9, 0x01, 0x42, 0x05, 0x17, 0x05, 0x41, 0xbb, 0x97, 0x9f, 0x66, 0x84, 0x81, 0x31,
+	0x13, 0x26, 0x03, 0xd6, 0x39, 0x45, 0x8f, 0xe5, 0xb2, 0x27, 0x28, 0xc5, 0x9e, 0xa1, 0xfb, 0x32,
+	0x13, 0x6b, 0x5d, 0x85, 0xbc, 0xf7, 0x7e, 0xf8, 0xf9, 0x70, 0x5f, 0xa1, 0xaa, 0x99, 0x1a, 0x57,
+	0xf9, 0x7a, 0xcc, 0x4b, 0xcd, 0x54, 0x89, 0x62, 0x2c, 0xeb, 0x8d, 0x7d, 0x5f, 0x73, 0xc1, 0xd6,
+	0x52, 0x6d, 0x50, 0x8f, 0x2a, 0x25, 0xb5, 0xa4, 0x9d, 0x46, 0x0c, 0x7e, 0x08, 0x04, 0x99, 0x90,
+	0x39, 0xbd, 0x01, 0x5f, 0xe1, 0x36, 0x26, 0x29, 0x19, 0x5e, 0x64, 0xc1, 0xfe, 0xb3, 0xd7, 0x5a,
+	0x58, 0x40, 0x7b, 0x10, 0x2a, 0xdc, 0xae, 0x6a, 0x6e, 0x58, 0xec, 0xa5, 0x64, 0xd8, 0x3e, 0xca,
+	0xae, 0xc2, 0xed, 0x92, 0x1b, 0x46, 0xfb, 0x10, 0x19, 0xc1, 0xf3, 0x55, 0x81, 0x1a, 0x63, 0xff,
+	0x6c, 0x3c, 0xb4, 0xf8, 0x09, 0x35, 0xda, 0x44, 0x98, 0x0d, 0x36, 0x49, 0x70, 0x9e, 0x58, 0xec,
+	0x92, 0x47, 0xb8, 0x9e, 0x67, 0xcb, 0xf9, 0xf3, 0xf4, 0x65, 0xba, 0xca, 0x0d, 0xaf, 0x1e, 0x9a,
+	0xb8, 0xed, 0xe2, 0x8e, 0x8d, 0x63, 0xb2, 0xb8, 0xfa, 0x4b, 0x32, 0x5b, 0xb8, 0xb9, 0x5b, 0x08,
+	0x85, 0x99, 0x34, 0x71, 0xc7, 0xc6, 0x8b, 0xae, 0x30, 0x13, 0xa7, 0xee, 0x20, 0x32, 0xb5, 0x2e,
+	0x1a, 0xd7, 0x75, 0x2e, 0xb4, 0xc0, 0xca, 0x81, 0x00, 0xb0, 0xbf, 0x3d, 0x63, 0x58, 0x30, 0x45,
+	0x63, 0x08, 0xf4, 0xae, 0x62, 0x31, 0x49, 0xbd, 0x61, 0x74, 0xdc, 0xcd, 0x11, 0x3a, 0x80, 0x88,
+	0x97, 0x05, 0x7b, 0x73, 0x1f, 0xf1, 0xce, 0x56, 0xff, 0xc7, 0x34, 0x85, 0xd0, 0x3e, 0xdd, 0x89,
+	0xfc, 0xd4, 0x3b, 0x9d, 0xe8, 0x44, 0xb3, 0xfe, 0xfe, 0x90, 0x90, 0x8f, 0x43, 0x42, 0xbe, 0x0e,
+	0x09, 0x79, 0xff, 0x4e, 0x5a, 0x70, 0xf9, 0xaa, 0x64, 0x9d, 0xef, 0x46, 0x39, 0x2f, 0x51, 0xed,
+	0x66, 0xfe, 0xef, 0x00, 0x6b, 0x14, 0xb0, 0x37, 0xbe, 0x01, 0x00, 0x00,
 }
 
@@ -596,6 +596,14 @@ type Way struct {
 	Vals []uint32 `protobuf:"varint,3,rep,packed,name=vals" json:"vals,omitempty"`
 	Info Info     `protobuf:"bytes,4,opt,name=info" json:"info"`
 	Refs []int64  `protobuf:"zigzag64,8,rep,packed,name=refs" json:"refs,omitempty"`
+	// The following two fields are optional. They are only used in a special
+	// format where node locations are also added to the ways. This makes the
+	// files larger, but allows creating way geometries directly.
+	//
+	// If one is set, the other has to be set, too, and both have to be of the
+	// same length as the refs field.
+	Lat []int64 `protobuf:"zigzag64,9,rep,packed,name=lat" json:"lat,omitempty"`
+	Lon []int64 `protobuf:"zigzag64,10,rep,packed,name=lon" json:"lon,omitempty"`
 }
 
 func (m *Way) Reset()                    { *m = Way{} }
@@ -638,6 +646,20 @@ func (m *Way) GetRefs() []int64 {
 	return nil
 }
 
+func (m *Way) GetLat() []int64 {
+	if m != nil {
+		return m.Lat
+	}
+	return nil
+}
+
+func (m *Way) GetLon() []int64 {
+	if m != nil {
+		return m.Lon
+	}
+	return nil
+}
+
 type Relation struct {
 	Id int64 `protobuf:"varint,1,req,name=id" json:"id"`
 	// Parallel arrays.
@@ -1439,6 +1461,42 @@ func (m *Way) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintOsmformat(dAtA, i, uint64(j40))
 		i += copy(dAtA[i:], dAtA42[:j40])
 	}
+	if len(m.Lat) > 0 {
+		var j100 int
+		dAtA102 := make([]byte, len(m.Lat)*10)
+		for _, num := range m.Lat {
+			x101 := (uint64(num) << 1) ^ uint64((num >> 63))
+			for x101 >= 1<<7 {
+				dAtA102[j100] = uint8(uint64(x101)&0x7f | 0x80)
+				j100++
+				x101 >>= 7
+			}
+			dAtA102[j100] = uint8(x101)
+			j100++
+		}
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintOsmformat(dAtA, i, uint64(j100))
+		i += copy(dAtA[i:], dAtA102[:j100])
+	}
+	if len(m.Lon) > 0 {
+		var j103 int
+		dAtA105 := make([]byte, len(m.Lon)*10)
+		for _, num := range m.Lon {
+			x104 := (uint64(num) << 1) ^ uint64((num >> 63))
+			for x104 >= 1<<7 {
+				dAtA105[j103] = uint8(uint64(x104)&0x7f | 0x80)
+				j103++
+				x104 >>= 7
+			}
+			dAtA105[j103] = uint8(x104)
+			j103++
+		}
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintOsmformat(dAtA, i, uint64(j103))
+		i += copy(dAtA[i:], dAtA105[:j103])
+	}
 	return i, nil
 }
 
@@ -1836,6 +1894,20 @@ func (m *Way) Size() (n int) {
 		}
 		n += 1 + sovOsmformat(uint64(l)) + l
 	}
+	if len(m.Lat) > 0 {
+		l = 0
+		for _, e := range m.Lat {
+			l += sozOsmformat(uint64(e))
+		}
+		n += 1 + sovOsmformat(uint64(l)) + l
+	}
+	if len(m.Lon) > 0 {
+		l = 0
+		for _, e := range m.Lon {
+			l += sozOsmformat(uint64(e))
+		}
+		n += 1 + sovOsmformat(uint64(l)) + l
+	}
 	return n
 }
 
@@ -4353,6 +4425,134 @@ func (m *Way) Unmarshal(dAtA []byte) error {
 			} else {
 				return fmt.Errorf("proto: wrong wireType = %d for field Refs", wireType)
 			}
+		case 9:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowOsmformat
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				v = (v >> 1) ^ uint64((int64(v&1)<<63)>>63)
+				m.Lat = append(m.Lat, int64(v))
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowOsmformat
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthOsmformat
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowOsmformat
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					v = (v >> 1) ^ uint64((int64(v&1)<<63)>>63)
+					m.Lat = append(m.Lat, int64(v))
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Lat", wireType)
+			}
+		case 10:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowOsmformat
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				v = (v >> 1) ^ uint64((int64(v&1)<<63)>>63)
+				m.Lon = append(m.Lon, int64(v))
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowOsmformat
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthOsmformat
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowOsmformat
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					v = (v >> 1) ^ uint64((int64(v&1)<<63)>>63)
+					m.Lon = append(m.Lon, int64(v))
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Lon", wireType)
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipOsmformat(dAtA[iNdEx:])
@@ -4901,76 +5101,76 @@ var (
 func init() { proto.RegisterFile("parser/pbf/internal/osmpbf/osmformat.proto", fileDescriptorOsmformat) }
 
 var fileDescriptorOsmformat = []byte{
-	// 1109 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x55, 0x4f, 0x6f, 0x1b, 0xc5,
-	0x1b, 0xee, 0xfe, 0x71, 0x62, 0xbf, 0x4e, 0xf3, 0x73, 0xa6, 0x51, 0xb5, 0xbf, 0x36, 0x4d, 0xb6,
-	0x8b, 0x8a, 0x56, 0x40, 0x6d, 0xd7, 0xa2, 0x42, 0xea, 0x01, 0xa9, 0xa6, 0xa5, 0xad, 0x04, 0x29,
-	0xda, 0x04, 0x2a, 0x4e, 0xd6, 0xd8, 0x3b, 0x76, 0x46, 0xdd, 0xdd, 0xd9, 0xce, 0xcc, 0xa6, 0xf1,
-	0x67, 0xe0, 0xc2, 0x1d, 0x89, 0x0b, 0x7c, 0x10, 0x38, 0x20, 0xf5, 0xc8, 0x27, 0x40, 0x28, 0x7c,
-	0x0b, 0x4e, 0x68, 0x66, 0x77, 0xbc, 0xeb, 0x24, 0x57, 0xb8, 0x79, 0x9e, 0xe7, 0x99, 0x77, 0xde,
-	0xe7, 0xfd, 0xb3, 0x86, 0x0f, 0x72, 0xcc, 0x05, 0xe1, 0x83, 0x7c, 0x3a, 0x1f, 0xd0, 0x4c, 0x12,
-	0x9e, 0xe1, 0x64, 0xc0, 0x44, 0xaa, 0xce, 0x4c, 0xa4, 0x73, 0xc6, 0x53, 0x2c, 0xfb, 0x39, 0x67,
-	0x92, 0xa1, 0x8d, 0x12, 0xbf, 0x75, 0x7f, 0x41, 0xe5, 0x49, 0x31, 0xed, 0xcf, 0x58, 0x3a, 0x58,
-	0xb0, 0x05, 0x1b, 0x68, 0x7a, 0x5a, 0xcc, 0xf5, 0x49, 0x1f, 0xf4, 0xaf, 0xf2, 0x5a, 0xf0, 0xab,
-	0x03, 0xdd, 0xe7, 0x04, 0xc7, 0x84, 0x8f, 0x13, 0x36, 0x7b, 0x8d, 0xde, 0x07, 0x77, 0x3a, 0x65,
-	0x67, 0x9e, 0xe5, 0x5b, 0x61, 0x77, 0x84, 0xfa, 0x65, 0xd4, 0x7e, 0x25, 0x19, 0xb3, 0xb3, 0x48,
-	0xf3, 0xe8, 0x43, 0xd8, 0xe1, 0xe4, 0x4d, 0x41, 0x39, 0x89, 0x27, 0x73, 0x82, 0x65, 0xc1, 0x89,
-	0xf0, 0x5c, 0xdf, 0x09, 0x3b, 0x51, 0xcf, 0x10, 0x9f, 0x57, 0xb8, 0x12, 0xb3, 0x5c, 0x52, 0x96,
-	0xe1, 0xa4, 0x16, 0xb7, 0x4a, 0xb1, 0x21, 0x56, 0xe2, 0x8f, 0x60, 0xfb, 0x2d, 0xa7, 0x92, 0x66,
-	0x8b, 0x9c, 0xb3, 0x05, 0xc7, 0xa9, 0xd7, 0xf3, 0xad, 0xb0, 0x33, 0x76, 0xdf, 0xfd, 0x71, 0x70,
-	0x2d, 0xba, 0xc0, 0xa1, 0x3d, 0xd8, 0x10, 0xac, 0xe0, 0x33, 0xe2, 0xed, 0x34, 0x54, 0x15, 0x86,
-	0x9e, 0xc3, 0x1d, 0x26, 0x52, 0x26, 0xa8, 0x98, 0x70, 0x92, 0x27, 0x74, 0x86, 0xd5, 0x63, 0x13,
-	0x49, 0x53, 0x22, 0x24, 0x4e, 0x73, 0xcf, 0xf7, 0xad, 0xd0, 0xa9, 0x2e, 0xdd, 0xae, 0xa4, 0x51,
-	0xad, 0x3c, 0x36, 0x42, 0x74, 0x04, 0xef, 0x5d, 0x15, 0x49, 0x90, 0x37, 0x05, 0xc9, 0x66, 0x64,
-	0x92, 0x15, 0xe9, 0x94, 0x70, 0xef, 0x6e, 0x23, 0x9e, 0x7f, 0x39, 0xde, 0x51, 0x25, 0x3f, 0xd4,
-	0x6a, 0xf4, 0x14, 0xf6, 0xae, 0x0a, 0x3a, 0xc5, 0x82, 0x4c, 0x0a, 0x9e, 0x78, 0x41, 0xc3, 0xd2,
-	0xff, 0x2f, 0x47, 0x1b, 0x63, 0x41, 0xbe, 0xe6, 0x49, 0x70, 0x06, 0x50, 0xf7, 0x07, 0x79, 0xe0,
-	0x26, 0x64, 0x2e, 0x3d, 0xcb, 0xb7, 0x43, 0x54, 0x5d, 0xd6, 0x08, 0xba, 0x05, 0x2d, 0x4e, 0x17,
-	0x27, 0xd2, 0xb3, 0x1b, 0x54, 0x09, 0xa1, 0x9b, 0xe0, 0x48, 0x96, 0x7b, 0x4e, 0x83, 0x51, 0x80,
-	0xaa, 0xef, 0x94, 0x49, 0xc9, 0x52, 0xcf, 0x6d, 0x50, 0x15, 0x16, 0xfc, 0x64, 0xc3, 0xf6, 0x57,
-	0x9c, 0xa6, 0x54, 0xd2, 0x53, 0x52, 0x0e, 0xd0, 0x43, 0xe8, 0x0a, 0xc9, 0x69, 0xb6, 0x90, 0x78,
-	0x9a, 0x10, 0x9d, 0x45, 0x77, 0x74, 0xc3, 0xcc, 0xd1, 0x91, 0xa6, 0x8e, 0x15, 0x15, 0x35, 0x75,
-	0xe8, 0x53, 0xd8, 0xce, 0x4d, 0xa0, 0x05, 0x67, 0x45, 0xee, 0xd9, 0xbe, 0x13, 0x76, 0x47, 0x37,
-	0xcd, 0xcd, 0xd5, 0x33, 0xcf, 0x14, 0x1b, 0x5d, 0x50, 0xa3, 0x7b, 0xd0, 0x5d, 0x70, 0x9c, 0x15,
-	0x09, 0xe6, 0x54, 0x2e, 0xf5, 0x30, 0xb4, 0x1e, 0x39, 0x0f, 0x86, 0xc3, 0xa8, 0x89, 0xa3, 0x01,
-	0xf4, 0x62, 0x2c, 0xc9, 0xa4, 0xa9, 0x45, 0x5a, 0xeb, 0x3e, 0x18, 0x0e, 0x87, 0xd1, 0xff, 0x14,
-	0xfb, 0xac, 0x71, 0xc1, 0x07, 0x48, 0xb0, 0x9c, 0xb0, 0xf9, 0x5c, 0x10, 0xe9, 0xdd, 0x50, 0xed,
-	0x7d, 0x64, 0x0d, 0xa3, 0x4e, 0x82, 0xe5, 0x4b, 0x8d, 0x69, 0x05, 0xcb, 0x8c, 0x62, 0xb7, 0x56,
-	0xb0, 0xac, 0x54, 0x04, 0x7f, 0x5b, 0x8d, 0x2a, 0xe9, 0xf4, 0x51, 0x08, 0xad, 0x8c, 0xc5, 0x44,
-	0x78, 0x96, 0x76, 0xb9, 0x65, 0x5c, 0x1e, 0xb2, 0x98, 0x98, 0xc6, 0x68, 0x81, 0x52, 0xc6, 0x24,
-	0x13, 0xc4, 0xb3, 0xd7, 0x37, 0xf2, 0x89, 0x02, 0x95, 0x5c, 0x44, 0xa5, 0x00, 0xdd, 0x03, 0xf7,
-	0x2d, 0x5e, 0x0a, 0xcf, 0xd1, 0x21, 0xbb, 0x46, 0xf8, 0x0a, 0x2f, 0xcd, 0x14, 0x28, 0x1a, 0x7d,
-	0x0c, 0x1d, 0x4e, 0x12, 0x3d, 0x40, 0xe5, 0xc6, 0x76, 0x47, 0x3d, 0xa3, 0x8d, 0x2a, 0xa2, 0xba,
-	0x50, 0x0b, 0xd1, 0x27, 0x00, 0xb3, 0x13, 0x9c, 0x2d, 0x88, 0x20, 0xb2, 0xdc, 0xdd, 0xee, 0x68,
-	0xc7, 0x5c, 0xfb, 0x4c, 0x33, 0x47, 0x44, 0x56, 0xf7, 0x1a, 0xd2, 0xe0, 0x36, 0x74, 0x1b, 0x4d,
-	0x47, 0x5b, 0x60, 0x95, 0xa6, 0xb7, 0x22, 0x4b, 0x04, 0xbf, 0x59, 0xe0, 0xbe, 0xc8, 0xe6, 0x0c,
-	0xed, 0xc1, 0xe6, 0x29, 0xe1, 0x82, 0xb2, 0x4c, 0x7f, 0x79, 0x5a, 0x8f, 0xec, 0xfb, 0x0f, 0x22,
-	0x03, 0xa1, 0x00, 0x3a, 0xf5, 0xca, 0xda, 0x8d, 0x15, 0xab, 0x61, 0xa5, 0x59, 0xbd, 0xea, 0x39,
-	0x4d, 0xcd, 0x0a, 0x56, 0x43, 0x5e, 0xd0, 0xd8, 0x73, 0xd5, 0x0b, 0x66, 0xc8, 0x0b, 0x1a, 0xa3,
-	0x03, 0x68, 0x17, 0x82, 0xf0, 0x89, 0xa0, 0xb1, 0xd7, 0xf2, 0xad, 0xf0, 0x7a, 0x45, 0x6e, 0x2a,
-	0xf4, 0x88, 0xc6, 0x68, 0x1f, 0x36, 0x4f, 0xa9, 0xa0, 0x6a, 0xa0, 0x37, 0x7c, 0x2b, 0x6c, 0x1b,
-	0xbe, 0x02, 0x83, 0x5f, 0x2c, 0xe8, 0xe8, 0x86, 0x5c, 0x36, 0xe3, 0x84, 0xad, 0xb1, 0xdd, 0xb3,
-	0x6a, 0x33, 0xfe, 0xba, 0x19, 0x27, 0x44, 0x9a, 0x6f, 0x58, 0xf1, 0xd7, 0xad, 0xac, 0x14, 0xb5,
-	0x91, 0x5d, 0x63, 0xc4, 0x09, 0x77, 0x34, 0xa7, 0x6d, 0xdc, 0x59, 0xb3, 0x61, 0xa8, 0x95, 0x89,
-	0xbd, 0xa6, 0x09, 0x27, 0x6c, 0x57, 0x69, 0x55, 0x16, 0xee, 0x42, 0x67, 0xd5, 0x46, 0xb4, 0x0b,
-	0x36, 0x8d, 0xf5, 0xee, 0x9a, 0x2a, 0xda, 0x34, 0x0e, 0x7e, 0xb6, 0xc0, 0x55, 0x13, 0xd7, 0xa0,
-	0x51, 0x4d, 0xa3, 0x9b, 0xe0, 0xbe, 0x26, 0x4b, 0xa1, 0x3d, 0x5d, 0xd7, 0xc1, 0xf5, 0x59, 0xe1,
-	0xa7, 0x38, 0x29, 0xe7, 0xb2, 0xc2, 0xd5, 0x59, 0xfd, 0xd5, 0xd0, 0x6c, 0xce, 0x74, 0x3b, 0x1a,
-	0x2b, 0xa0, 0x4a, 0x68, 0x06, 0x56, 0xf1, 0xaa, 0x6b, 0x09, 0x96, 0x5e, 0xbb, 0xf9, 0x69, 0x4a,
-	0xb0, 0xee, 0x66, 0xc2, 0x32, 0xaf, 0xb3, 0x86, 0xb3, 0x2c, 0xf8, 0xd1, 0x02, 0xa8, 0xb7, 0x03,
-	0xa1, 0x2a, 0x59, 0x53, 0x46, 0x95, 0xea, 0x00, 0x3a, 0x7a, 0x67, 0xf4, 0xfb, 0x2d, 0xfd, 0xfe,
-	0xce, 0xda, 0x62, 0xa9, 0x24, 0xa2, 0x5a, 0xa3, 0x0a, 0x5e, 0xe6, 0x60, 0xa2, 0xe8, 0x0c, 0x76,
-	0x4d, 0x06, 0x35, 0xca, 0x32, 0x74, 0x00, 0x1d, 0xe5, 0x7b, 0xa2, 0x4d, 0xc3, 0x6a, 0x00, 0xda,
-	0x0a, 0xfc, 0x06, 0x27, 0x22, 0xf8, 0xce, 0x02, 0xe7, 0x15, 0x5e, 0x5e, 0x5d, 0xe5, 0x7f, 0xb1,
-	0x8c, 0x2e, 0x27, 0x73, 0xd1, 0xf0, 0xa0, 0xcf, 0xc1, 0x0f, 0x36, 0xb4, 0xcd, 0xde, 0xff, 0xc7,
-	0x29, 0x1d, 0x40, 0x87, 0xb3, 0x84, 0x08, 0x3d, 0xb1, 0xed, 0xba, 0x52, 0x1a, 0x54, 0x23, 0x7b,
-	0x0b, 0x36, 0x52, 0x92, 0xd2, 0x58, 0x34, 0x6a, 0x5c, 0x21, 0xe8, 0x21, 0xb4, 0xe4, 0x32, 0x27,
-	0x65, 0x89, 0xb7, 0x47, 0xb7, 0x2f, 0x7e, 0xc3, 0xfa, 0x5f, 0x12, 0xf5, 0x27, 0x7b, 0xbc, 0xcc,
-	0x89, 0xbe, 0x57, 0xaa, 0x83, 0xfb, 0x00, 0x35, 0x81, 0xda, 0xe0, 0x1e, 0xbe, 0x7c, 0xf2, 0xb4,
-	0x77, 0x0d, 0x6d, 0x82, 0xf3, 0xea, 0xf1, 0xb7, 0x3d, 0x0b, 0x6d, 0x41, 0x3b, 0x7a, 0xfa, 0xc5,
-	0xe3, 0xe3, 0x17, 0x2f, 0x0f, 0x7b, 0xf6, 0xf8, 0xee, 0xbb, 0xf3, 0x7d, 0xeb, 0xf7, 0xf3, 0x7d,
-	0xeb, 0xcf, 0xf3, 0x7d, 0xeb, 0xfb, 0xbf, 0xf6, 0xaf, 0xc1, 0xf5, 0x19, 0x67, 0x62, 0xba, 0xec,
-	0x4f, 0x69, 0x86, 0xf9, 0xf2, 0xb9, 0xf3, 0x4f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xee, 0x1f, 0xea,
-	0x2a, 0xa6, 0x09, 0x00, 0x00,
+	// 1110 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x55, 0xdf, 0x8e, 0xdb, 0xc4,
+	0x17, 0xae, 0xff, 0x64, 0x37, 0x3e, 0xd9, 0xee, 0x2f, 0x3b, 0x5d, 0x55, 0xfe, 0xb5, 0xdb, 0x5d,
+	0xd7, 0xa8, 0xc8, 0x02, 0x9a, 0xa4, 0x11, 0x15, 0x52, 0x2f, 0x90, 0x1a, 0x5a, 0xda, 0x4a, 0xb0,
+	0x45, 0xde, 0x85, 0x8a, 0xab, 0x68, 0x12, 0x4f, 0xd2, 0x51, 0x6d, 0x8f, 0x3b, 0x33, 0xde, 0x36,
+	0x6f, 0xc1, 0x3d, 0x12, 0x37, 0xf0, 0x0e, 0xdc, 0xc2, 0x05, 0x52, 0x2f, 0x79, 0x02, 0x84, 0xca,
+	0x5b, 0x70, 0x85, 0x66, 0xec, 0x89, 0x9d, 0xee, 0xde, 0xc2, 0x5d, 0xe6, 0xfb, 0xbe, 0x73, 0xe6,
+	0x7c, 0x73, 0xce, 0x71, 0xe0, 0x83, 0x02, 0x73, 0x41, 0xf8, 0xb0, 0x98, 0x2d, 0x86, 0x34, 0x97,
+	0x84, 0xe7, 0x38, 0x1d, 0x32, 0x91, 0xa9, 0x33, 0x13, 0xd9, 0x82, 0xf1, 0x0c, 0xcb, 0x41, 0xc1,
+	0x99, 0x64, 0x68, 0xab, 0xc2, 0xaf, 0xdd, 0x5e, 0x52, 0xf9, 0xbc, 0x9c, 0x0d, 0xe6, 0x2c, 0x1b,
+	0x2e, 0xd9, 0x92, 0x0d, 0x35, 0x3d, 0x2b, 0x17, 0xfa, 0xa4, 0x0f, 0xfa, 0x57, 0x15, 0x16, 0xfe,
+	0xea, 0x40, 0xef, 0x31, 0xc1, 0x09, 0xe1, 0x93, 0x94, 0xcd, 0x5f, 0xa0, 0xf7, 0xc1, 0x9d, 0xcd,
+	0xd8, 0x6b, 0xdf, 0x0a, 0xac, 0xa8, 0x37, 0x46, 0x83, 0x2a, 0xeb, 0xa0, 0x96, 0x4c, 0xd8, 0xeb,
+	0x58, 0xf3, 0xe8, 0x43, 0xd8, 0xe3, 0xe4, 0x65, 0x49, 0x39, 0x49, 0xa6, 0x0b, 0x82, 0x65, 0xc9,
+	0x89, 0xf0, 0xdd, 0xc0, 0x89, 0xbc, 0xb8, 0x6f, 0x88, 0xcf, 0x6b, 0x5c, 0x89, 0x59, 0x21, 0x29,
+	0xcb, 0x71, 0xda, 0x88, 0x3b, 0x95, 0xd8, 0x10, 0x6b, 0xf1, 0x47, 0xb0, 0xfb, 0x8a, 0x53, 0x49,
+	0xf3, 0x65, 0xc1, 0xd9, 0x92, 0xe3, 0xcc, 0xef, 0x07, 0x56, 0xe4, 0x4d, 0xdc, 0x37, 0x7f, 0x1c,
+	0x5d, 0x8a, 0xdf, 0xe1, 0xd0, 0x01, 0x6c, 0x09, 0x56, 0xf2, 0x39, 0xf1, 0xf7, 0x5a, 0xaa, 0x1a,
+	0x43, 0x8f, 0xe1, 0x06, 0x13, 0x19, 0x13, 0x54, 0x4c, 0x39, 0x29, 0x52, 0x3a, 0xc7, 0xea, 0xb2,
+	0xa9, 0xa4, 0x19, 0x11, 0x12, 0x67, 0x85, 0x1f, 0x04, 0x56, 0xe4, 0xd4, 0x41, 0xd7, 0x6b, 0x69,
+	0xdc, 0x28, 0x4f, 0x8d, 0x10, 0x9d, 0xc0, 0x7b, 0x17, 0x65, 0x12, 0xe4, 0x65, 0x49, 0xf2, 0x39,
+	0x99, 0xe6, 0x65, 0x36, 0x23, 0xdc, 0xbf, 0xd9, 0xca, 0x17, 0x9c, 0xcf, 0x77, 0x52, 0xcb, 0x8f,
+	0xb5, 0x1a, 0x3d, 0x84, 0x83, 0x8b, 0x92, 0xce, 0xb0, 0x20, 0xd3, 0x92, 0xa7, 0x7e, 0xd8, 0xb2,
+	0xf4, 0xff, 0xf3, 0xd9, 0x26, 0x58, 0x90, 0xaf, 0x79, 0x1a, 0xbe, 0x06, 0x68, 0xfa, 0x83, 0x7c,
+	0x70, 0x53, 0xb2, 0x90, 0xbe, 0x15, 0xd8, 0x11, 0xaa, 0x83, 0x35, 0x82, 0xae, 0x41, 0x87, 0xd3,
+	0xe5, 0x73, 0xe9, 0xdb, 0x2d, 0xaa, 0x82, 0xd0, 0x55, 0x70, 0x24, 0x2b, 0x7c, 0xa7, 0xc5, 0x28,
+	0x40, 0xbd, 0xef, 0x8c, 0x49, 0xc9, 0x32, 0xdf, 0x6d, 0x51, 0x35, 0x16, 0xfe, 0x68, 0xc3, 0xee,
+	0x57, 0x9c, 0x66, 0x54, 0xd2, 0x33, 0x52, 0x0d, 0xd0, 0x5d, 0xe8, 0x09, 0xc9, 0x69, 0xbe, 0x94,
+	0x78, 0x96, 0x12, 0x5d, 0x45, 0x6f, 0x7c, 0xc5, 0xcc, 0xd1, 0x89, 0xa6, 0x4e, 0x15, 0x15, 0xb7,
+	0x75, 0xe8, 0x53, 0xd8, 0x2d, 0x4c, 0xa2, 0x25, 0x67, 0x65, 0xe1, 0xdb, 0x81, 0x13, 0xf5, 0xc6,
+	0x57, 0x4d, 0xe4, 0xfa, 0x9a, 0x47, 0x8a, 0x8d, 0xdf, 0x51, 0xa3, 0x5b, 0xd0, 0x5b, 0x72, 0x9c,
+	0x97, 0x29, 0xe6, 0x54, 0xae, 0xf4, 0x30, 0x74, 0xee, 0x39, 0x77, 0x46, 0xa3, 0xb8, 0x8d, 0xa3,
+	0x21, 0xf4, 0x13, 0x2c, 0xc9, 0xb4, 0xad, 0x45, 0x5a, 0xeb, 0xde, 0x19, 0x8d, 0x46, 0xf1, 0xff,
+	0x14, 0xfb, 0xa8, 0x15, 0x10, 0x00, 0xa4, 0x58, 0x4e, 0xd9, 0x62, 0x21, 0x88, 0xf4, 0xaf, 0xa8,
+	0xf6, 0xde, 0xb3, 0x46, 0xb1, 0x97, 0x62, 0xf9, 0x54, 0x63, 0x5a, 0xc1, 0x72, 0xa3, 0xd8, 0x6f,
+	0x14, 0x2c, 0xaf, 0x14, 0xe1, 0xdf, 0x16, 0xec, 0x6e, 0x96, 0x8f, 0x22, 0xe8, 0xe4, 0x2c, 0x21,
+	0xc2, 0xb7, 0xb4, 0xcb, 0x1d, 0xe3, 0xf2, 0x98, 0x25, 0xc4, 0x34, 0x46, 0x0b, 0x94, 0x32, 0x21,
+	0xb9, 0x20, 0xbe, 0xbd, 0xb9, 0x91, 0x0f, 0x14, 0xa8, 0xe4, 0x22, 0xae, 0x04, 0xe8, 0x16, 0xb8,
+	0xaf, 0xf0, 0x4a, 0xf8, 0x8e, 0x4e, 0xd9, 0x33, 0xc2, 0x67, 0x78, 0x65, 0xa6, 0x40, 0xd1, 0xe8,
+	0x63, 0xf0, 0x38, 0x49, 0xf5, 0x00, 0x55, 0x1b, 0xdb, 0x1b, 0xf7, 0x8d, 0x36, 0xae, 0x89, 0x3a,
+	0xa0, 0x11, 0xa2, 0x4f, 0x00, 0xe6, 0xcf, 0x71, 0xbe, 0x24, 0x82, 0xc8, 0x6a, 0x77, 0x7b, 0xe3,
+	0x3d, 0x13, 0xf6, 0x99, 0x66, 0x4e, 0x88, 0xac, 0xe3, 0x5a, 0xd2, 0xf0, 0x3a, 0xf4, 0x5a, 0x4d,
+	0x47, 0x3b, 0x60, 0x55, 0xa6, 0x77, 0x62, 0x4b, 0x84, 0xbf, 0x59, 0xe0, 0x3e, 0xc9, 0x17, 0x0c,
+	0x1d, 0xc0, 0xf6, 0x19, 0xe1, 0x82, 0xb2, 0x5c, 0x7f, 0x79, 0x3a, 0xf7, 0xec, 0xdb, 0x77, 0x62,
+	0x03, 0xa1, 0x10, 0xbc, 0x66, 0x65, 0xed, 0xd6, 0x8a, 0x35, 0xb0, 0xd2, 0xac, 0x6f, 0xf5, 0x9d,
+	0xb6, 0x66, 0x0d, 0xab, 0x21, 0x2f, 0x69, 0xe2, 0xbb, 0xea, 0x86, 0x9a, 0x55, 0x00, 0x3a, 0x82,
+	0x6e, 0x29, 0x08, 0x9f, 0x0a, 0x9a, 0xf8, 0x9d, 0xc0, 0x8a, 0x2e, 0xd7, 0xe4, 0xb6, 0x42, 0x4f,
+	0x68, 0x82, 0x0e, 0x61, 0xfb, 0x8c, 0x0a, 0xaa, 0x06, 0x7a, 0x2b, 0xb0, 0xa2, 0xae, 0xe1, 0x6b,
+	0x30, 0xfc, 0xc5, 0x02, 0x4f, 0x37, 0xe4, 0xbc, 0x19, 0x27, 0xea, 0x4c, 0xec, 0xbe, 0xd5, 0x98,
+	0x09, 0x36, 0xcd, 0x38, 0x11, 0xd2, 0x7c, 0xcb, 0x4a, 0xb0, 0x69, 0x65, 0xad, 0x68, 0x8c, 0xec,
+	0x1b, 0x23, 0x4e, 0xb4, 0xa7, 0x39, 0x6d, 0xe3, 0xc6, 0x86, 0x0d, 0x43, 0xad, 0x4d, 0x1c, 0xb4,
+	0x4d, 0x38, 0x51, 0xb7, 0x2e, 0xab, 0xb6, 0x70, 0x13, 0xbc, 0x75, 0x1b, 0xd1, 0x3e, 0xd8, 0x34,
+	0xd1, 0xbb, 0x6b, 0x5e, 0xd1, 0xa6, 0x49, 0xf8, 0x93, 0x05, 0xae, 0x9a, 0xb8, 0x16, 0x8d, 0x1a,
+	0x1a, 0x5d, 0x05, 0xf7, 0x05, 0x59, 0x09, 0xed, 0xe9, 0xb2, 0x4e, 0xae, 0xcf, 0x0a, 0x3f, 0xc3,
+	0x69, 0x35, 0x97, 0x35, 0xae, 0xce, 0xea, 0xaf, 0x86, 0xe6, 0x0b, 0xa6, 0xdb, 0xd1, 0x5a, 0x01,
+	0xf5, 0x84, 0x66, 0x60, 0x15, 0xaf, 0xba, 0x96, 0x62, 0xe9, 0x77, 0xdb, 0x9f, 0xa6, 0x14, 0xeb,
+	0x6e, 0xa6, 0x2c, 0xf7, 0xbd, 0x0d, 0x9c, 0xe5, 0xe1, 0x0f, 0x16, 0x40, 0xb3, 0x1d, 0x08, 0xd5,
+	0xc5, 0x9a, 0x67, 0x54, 0xa5, 0x0e, 0xc1, 0xd3, 0x3b, 0xa3, 0xef, 0xef, 0x04, 0x56, 0x7b, 0x98,
+	0xd7, 0x7d, 0x8c, 0x1b, 0x0d, 0xda, 0x37, 0x35, 0x98, 0x2c, 0xba, 0x82, 0x7d, 0x53, 0x41, 0x83,
+	0xb2, 0x1c, 0x1d, 0x81, 0xa7, 0x7c, 0x4f, 0xb5, 0x69, 0x58, 0x0f, 0x40, 0x57, 0x81, 0xdf, 0xe0,
+	0x54, 0x84, 0x3f, 0x5b, 0xe0, 0x3c, 0xc3, 0xab, 0x8b, 0x5f, 0xf9, 0x5f, 0x7c, 0x46, 0x97, 0x93,
+	0x85, 0x68, 0x79, 0xd0, 0x67, 0x63, 0xcd, 0xbb, 0xd0, 0x1a, 0x6c, 0x58, 0x0b, 0xbf, 0xb7, 0xa1,
+	0x6b, 0xbe, 0x11, 0xff, 0x71, 0xf9, 0x47, 0xe0, 0x71, 0x96, 0x12, 0xa1, 0xa7, 0xbb, 0xdb, 0xbc,
+	0xaa, 0x06, 0xd5, 0x78, 0x5f, 0x83, 0xad, 0x8c, 0x64, 0x34, 0x11, 0x2d, 0x2b, 0x35, 0x82, 0xee,
+	0x42, 0x47, 0xae, 0x0a, 0x52, 0xb5, 0x63, 0x77, 0x7c, 0xfd, 0xdd, 0xef, 0xdd, 0xe0, 0x4b, 0xa2,
+	0xfe, 0x90, 0x4f, 0x57, 0x05, 0xd1, 0x71, 0x95, 0x3a, 0xbc, 0x0d, 0xd0, 0x10, 0xa8, 0x0b, 0xee,
+	0xf1, 0xd3, 0x07, 0x0f, 0xfb, 0x97, 0xd0, 0x36, 0x38, 0xcf, 0xee, 0x7f, 0xdb, 0xb7, 0xd0, 0x0e,
+	0x74, 0xe3, 0x87, 0x5f, 0xdc, 0x3f, 0x7d, 0xf2, 0xf4, 0xb8, 0x6f, 0x4f, 0x6e, 0xbe, 0x79, 0x7b,
+	0x68, 0xfd, 0xfe, 0xf6, 0xd0, 0xfa, 0xf3, 0xed, 0xa1, 0xf5, 0xdd, 0x5f, 0x87, 0x97, 0xe0, 0xf2,
+	0x9c, 0x33, 0x31, 0x5b, 0x0d, 0x66, 0x34, 0xc7, 0x7c, 0xf5, 0xd8, 0xf9, 0x67, 0x00, 0xb1, 0xde,
+	0xbd, 0xd9, 0xd2, 0x09, 0x00, 0x00,
 }
 
@@ -2,7 +2,6 @@ package pbf
 
 import (
 	"bytes"
-	"compress/zlib"
 	structs "encoding/binary"
 	"errors"
 	"fmt"
@@ -10,14 +9,32 @@ import (
 	"time"
 
 	"github.com/gogo/protobuf/proto"
+	"github.com/klauspost/compress/zstd"
 	"github.com/omniscale/go-osm/parser/pbf/internal/osmpbf"
+	"github.com/ulikunitz/xz/lzma"
 )
 
-var supportedFeatured = map[string]bool{"OsmSchema-V0.6": true, "DenseNodes": true}
+var supportedFeatured = map[string]bool{
+	"OsmSchema-V0.6":        true,
+	"DenseNodes":            true,
+	"LocationsOnWays":       true,
+	"HistoricalInformation": true,
+}
+
+const (
+	locationsOnWays       = "LocationsOnWays"
+	historicalInformation = "HistoricalInformation"
+)
+
+// maxBlobSize is the maximum size of an uncompressed blob, as defined by
+// the OSM PBF specification.
+const maxBlobSize = 32 * 1024 * 1024
 
 // decodeRawBlob decodes Blob PBF messages and returns either the raw bytes or
-// the uncompressed zlib_data bytes. The result can contain encoded HeaderBlock
-// or PrimitiveBlock PBF messages.
+// the uncompressed zlib_data, zstd_data, lz4_data or lzma_data bytes. The
+// result can contain encoded HeaderBlock or PrimitiveBlock PBF messages.
+// Compressed data is uncompressed into a buffer from the bufPool. The result
+// can be returned with putBuf once it is no longer used.
 func decodeRawBlob(raw []byte) ([]byte, error) {
 	blob := &osmpbf.Blob{}
 
@@ -26,30 +43,78 @@ func decodeRawBlob(raw []byte) ([]byte, error) {
 		return nil, fmt.Errorf("unmarshaling blob: %w", err)
 	}
 
-	// pbf contains (uncompressed) raw or zlibdata
-	b := blob.GetRaw()
-	if b == nil {
-		buf := bytes.NewBuffer(blob.GetZlibData())
-		r, err := zlib.NewReader(buf)
+	if blob.Raw == nil && (blob.RawSize < 0 || blob.RawSize > maxBlobSize) {
+		// check before the buffer for the uncompressed data is allocated
+		return nil, fmt.Errorf("invalid raw size %d of blob, expected 0 to %d bytes", blob.RawSize, maxBlobSize)
+	}
+
+	switch {
+	case blob.Raw != nil:
+		return blob.Raw, nil
+	case blob.ZlibData != nil:
+		r, err := getZlibReader(bytes.NewReader(blob.ZlibData))
 		if err != nil {
 			return nil, fmt.Errorf("start uncompressing ZLibData: %w", err)
 		}
-		b = make([]byte, blob.GetRawSize())
+		defer putZlibReader(r)
+		b := getBuf(int(blob.RawSize))
 		_, err = io.ReadFull(r, b)
 		if err != nil {
+			putBuf(b)
 			return nil, fmt.Errorf("uncompressing ZLibData: %w", err)
 		}
+		return b, nil
+	case blob.ZstdData != nil:
+		buf := getBuf(int(blob.RawSize))
+		b, err := zstdDecoder.DecodeAll(blob.ZstdData, buf[:0])
+		if err != nil {
+			putBuf(buf)
+			return nil, fmt.Errorf("uncompressing ZstdData: %w", err)
+		}
+		return b, nil
+	case blob.Lz4Data != nil:
+		b := getBuf(int(blob.RawSize))
+		if err := decodeLZ4Block(b, blob.Lz4Data); err != nil {
+			putBuf(b)
+			return nil, fmt.Errorf("uncompressing Lz4Data: %w", err)
+		}
+		return b, nil
+	case blob.LzmaData != nil:
+		r, err := lzma.NewReader(bytes.NewReader(blob.LzmaData))
+		if err != nil {
+			return nil, fmt.Errorf("start uncompressing LzmaData: %w", err)
+		}
+		b := getBuf(int(blob.RawSize))
+		_, err = io.ReadFull(r, b)
+		if err != nil {
+			putBuf(b)
+			return nil, fmt.Errorf("uncompressing LzmaData: %w", err)
+		}
+		return b, nil
+	case blob.OBSOLETEBzip2Data != nil:
+		return nil, fmt.Errorf("%w: bzip2", ErrUnsupportedCompression)
 	}
-	return b, nil
+	return nil, fmt.Errorf("%w: blob without data", ErrUnsupportedCompression)
 }
 
-func decodePrimitiveBlock(blob []byte) (*osmpbf.PrimitiveBlock, error) {
+// ErrUnsupportedCompression is returned for blobs that are compressed with
+// an unknown or unsupported codec.
+var ErrUnsupportedCompression = errors.New("unsupported compression")
+
+// zstdDecoder is safe for concurrent use with DecodeAll.
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+
+// decodePrimitiveBlock decodes the PrimitiveBlock with the slices from bb.
+// The result is only valid till the next call with the same bb.
+func decodePrimitiveBlock(blob []byte, bb *blockBuf) (*osmpbf.PrimitiveBlock, error) {
 	b, err := decodeRawBlob(blob)
 	if err != nil {
 		return nil, fmt.Errorf("decoding raw blob: %w", err)
 	}
-	block := &osmpbf.PrimitiveBlock{}
-	if err = proto.Unmarshal(b, block); err != nil {
+	block, err := bb.unmarshal(b)
+	// block contains copies of all values
+	putBuf(b)
+	if err != nil {
 		return nil, fmt.Errorf("unmarshaling PrimitiveBlock: %w", err)
 	}
 	return block, nil
@@ -62,7 +127,9 @@ func decodeHeaderBlock(blob []byte) (*Header, error) {
 	}
 
 	header := &osmpbf.HeaderBlock{}
-	if err := proto.Unmarshal(b, header); err != nil {
+	err = proto.Unmarshal(b, header)
+	putBuf(b)
+	if err != nil {
 		return nil, err
 	}
 
@@ -79,14 +146,69 @@ func decodeHeaderBlock(blob []byte) (*Header, error) {
 		result.Time = time.Unix(timestamp, 0)
 	}
 	result.Sequence = header.GetOsmosisReplicationSequenceNumber()
+	result.ReplicationBaseURL = header.GetOsmosisReplicationBaseUrl()
 	result.RequiredFeatures = header.RequiredFeatures
 	result.OptionalFeatures = header.OptionalFeatures
+	for _, feature := range append(header.RequiredFeatures, header.OptionalFeatures...) {
+		switch feature {
+		case locationsOnWays:
+			result.LocationsOnWays = true
+		case historicalInformation:
+			result.HistoricalInformation = true
+		}
+	}
+	result.WritingProgram = header.GetWritingprogram()
+	result.Source = header.GetSource()
+	if bbox := header.GetBbox(); bbox != nil {
+		result.BBox = [4]float64{
+			bboxScale * float64(bbox.Left),
+			bboxScale * float64(bbox.Bottom),
+			bboxScale * float64(bbox.Right),
+			bboxScale * float64(bbox.Top),
+		}
+	}
 	return result, nil
 }
 
+// HeaderBBox coordinates are always stored in nanodegrees.
+const bboxScale = 0.000000001
+
+// Header contains the information from the HeaderBlock of a PBF file.
 type Header struct {
-	Time     time.Time
+	// Time of the replication state of this file
+	// (osmosis_replication_timestamp). Zero if not set.
+	Time time.Time
+	// Sequence of the replication state of this file
+	// (osmosis_replication_sequence_number).
 	Sequence int64
+	// ReplicationBaseURL of the replication server
+	// (osmosis_replication_base_url). Time, Sequence and ReplicationBaseURL
+	// can be used to start updating the data with diff files.
+	ReplicationBaseURL string
+
+	// BBox contains the bounding box of the data as min longitude, min
+	// latitude, max longitude and max latitude (left, bottom, right and top
+	// of the bbox). All values are zero if the file contains no bbox.
+	BBox [4]float64
+
+	// WritingProgram is the name of the program that created this file
+	// (writingprogram).
+	WritingProgram string
+	// Source describes the source of the data, e.g. the URL of the OSM API
+	// (source).
+	Source string
+
+	// LocationsOnWays is true if the ways in this file also contain the
+	// locations of their nodes (e.g. files created with osmium
+	// add-locations-to-ways). The parser sets the Way.Nodes for these files.
+	LocationsOnWays bool
+
+	// HistoricalInformation is true if the file contains the history of
+	// the elements (e.g. full-history planet files). These files can
+	// contain multiple versions of the same element and deleted elements.
+	// Use IncludeMetadata to get the version and visible flag of each
+	// element.
+	HistoricalInformation bool
 
 	RequiredFeatures []string
 	OptionalFeatures []string
@@ -101,6 +223,7 @@ func parseHeader(r io.Reader) (*Header, error) {
 		return nil, errors.New("invalid block type, expected OSMHeader, got " + blockHeader.GetType())
 	}
 	header, err := decodeHeaderBlock(data)
+	putBuf(data)
 	return header, err
 }
 
@@ -114,9 +237,10 @@ func nextBlock(r io.Reader) (*osmpbf.BlobHeader, []byte, error) {
 	}
 	size := header.GetDatasize()
 
-	data := make([]byte, size)
+	data := getBuf(int(size))
 	n, err := io.ReadFull(r, data)
 	if err != nil {
+		putBuf(data)
 		return nil, nil, fmt.Errorf("reading next block: %w", err)
 	}
 	if n != int(size) {
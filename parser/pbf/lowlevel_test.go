@@ -0,0 +1,108 @@
+package pbf
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/omniscale/go-osm/parser/pbf/internal/osmpbf"
+)
+
+func TestParseCompression(t *testing.T) {
+	f, err := os.Open("./testdata/monaco-sample-zlib.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parseAll(t, f, true)
+	f.Close()
+	if len(want.nodes) == 0 || len(want.ways) != 300 || len(want.relations) != 30 {
+		t.Fatal("unexpected number of elements in fixture", len(want.nodes), len(want.ways), len(want.relations))
+	}
+
+	for _, codec := range []string{"zstd", "lz4", "lzma"} {
+		t.Run(codec, func(t *testing.T) {
+			f, err := os.Open("./testdata/monaco-sample-" + codec + ".osm.pbf")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got := parseAll(t, f, true)
+			if !reflect.DeepEqual(got.header, want.header) {
+				t.Errorf("unexpected header %#v", got.header)
+			}
+			if !reflect.DeepEqual(got.nodes, want.nodes) {
+				t.Error("nodes differ")
+			}
+			if !reflect.DeepEqual(got.ways, want.ways) {
+				t.Error("ways differ")
+			}
+			if !reflect.DeepEqual(got.relations, want.relations) {
+				t.Error("relations differ")
+			}
+		})
+	}
+}
+
+func TestDecodeRawBlobUnsupported(t *testing.T) {
+	for _, blob := range []*osmpbf.Blob{
+		{RawSize: 3, OBSOLETEBzip2Data: []byte("BZh")},
+		{RawSize: 3},
+	} {
+		raw, err := proto.Marshal(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decodeRawBlob(raw); !errors.Is(err, ErrUnsupportedCompression) {
+			t.Errorf("expected ErrUnsupportedCompression, got %v", err)
+		}
+	}
+}
+
+func TestDecodeRawBlobInvalidSize(t *testing.T) {
+	data := []byte("foo")
+	for _, blob := range []*osmpbf.Blob{
+		{RawSize: -1, ZlibData: data},
+		{RawSize: maxBlobSize + 1, ZlibData: data},
+		{RawSize: -1, ZstdData: data},
+		{RawSize: maxBlobSize + 1, ZstdData: data},
+		{RawSize: -1, Lz4Data: data},
+		{RawSize: maxBlobSize + 1, Lz4Data: data},
+		{RawSize: -1, LzmaData: data},
+		{RawSize: maxBlobSize + 1, LzmaData: data},
+	} {
+		raw, err := proto.Marshal(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = decodeRawBlob(raw)
+		if err == nil || !strings.Contains(err.Error(), "invalid raw size") {
+			t.Errorf("expected invalid raw size error, got %v", err)
+		}
+	}
+}
+
+func TestDecodeLZ4Block(t *testing.T) {
+	// "abc" literals followed by a match with offset 3 and length 9
+	src := []byte{0x35, 'a', 'b', 'c', 3, 0, 0x10, 'd'}
+	dst := make([]byte, 13)
+	if err := decodeLZ4Block(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if string(dst) != "abcabcabcabcd" {
+		t.Errorf("unexpected result %q", dst)
+	}
+
+	for _, src := range [][]byte{
+		{0x35, 'a', 'b', 'c', 4, 0},       // offset before start
+		{0x35, 'a', 'b', 'c', 3},          // truncated offset
+		{0xf0, 255},                       // truncated length
+		{0x35, 'a', 'b', 'c', 3, 0, 0x10}, // missing last literals
+	} {
+		if err := decodeLZ4Block(make([]byte, 13), src); err == nil {
+			t.Errorf("expected error for %v", src)
+		}
+	}
+}
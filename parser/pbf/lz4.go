@@ -0,0 +1,76 @@
+package pbf
+
+import "errors"
+
+var errInvalidLZ4 = errors.New("invalid LZ4 block")
+
+// decodeLZ4Block decodes a single LZ4 block (without the LZ4 frame header)
+// into dst. dst needs to be the exact size of the uncompressed data.
+func decodeLZ4Block(dst, src []byte) error {
+	var si, di int
+	for si < len(src) {
+		token := src[si]
+		si++
+
+		// literals
+		n, err := lz4Length(src, &si, int(token>>4))
+		if err != nil {
+			return err
+		}
+		if si+n > len(src) || di+n > len(dst) {
+			return errInvalidLZ4
+		}
+		di += copy(dst[di:], src[si:si+n])
+		si += n
+		if si == len(src) {
+			// last sequence only contains literals
+			break
+		}
+
+		// match
+		if si+2 > len(src) {
+			return errInvalidLZ4
+		}
+		offset := int(src[si]) | int(src[si+1])<<8
+		si += 2
+		if offset == 0 || offset > di {
+			return errInvalidLZ4
+		}
+		n, err = lz4Length(src, &si, int(token&0xf))
+		if err != nil {
+			return err
+		}
+		n += 4
+		if di+n > len(dst) {
+			return errInvalidLZ4
+		}
+		// matches can overlap with the output, copy byte by byte
+		for i := 0; i < n; i++ {
+			dst[di] = dst[di-offset]
+			di++
+		}
+	}
+	if di != len(dst) {
+		return errInvalidLZ4
+	}
+	return nil
+}
+
+// lz4Length returns the literal or match length, reading the additional
+// length bytes from src if n is 15.
+func lz4Length(src []byte, si *int, n int) (int, error) {
+	if n != 15 {
+		return n, nil
+	}
+	for {
+		if *si >= len(src) {
+			return 0, errInvalidLZ4
+		}
+		b := src[*si]
+		*si++
+		n += int(b)
+		if b != 255 {
+			return n, nil
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
 )
 
 type Config struct {
@@ -33,7 +34,8 @@ type Config struct {
 	//
 	// If a Coords channel is specified, then nodes without tags are
 	// not sent to the Nodes channel. However, the Coords channel will receive
-	// all nodes.
+	// all nodes, except deleted nodes of files with historical information
+	// as they have no location.
 	Coords chan []osm.Node
 
 	// KeepOpen specifies whether the destination channels should be keept open
@@ -41,6 +43,20 @@ type Config struct {
 	// are closed after Parse().
 	KeepOpen bool
 
+	// Filter selects the elements that are parsed. Skipped elements are
+	// not sent to the channels or the Handler. See Filter for details.
+	Filter Filter
+
+	// Handler specifies an optional Handler that is called for each parsed
+	// element. Handler can be used as an alternative or in addition to the
+	// channels above. It is called before the elements are sent to the
+	// channels. OnFirstWay and OnFirstRelation are called before the first
+	// way or relation is passed to the Handler.
+	//
+	// With RecycleBatches, batches are recycled after all Handler calls if
+	// there is no channel for their type.
+	Handler Handler
+
 	// OnFirstWay defines an optional func that gets called when the the first
 	// way is parsed. The callback should block until it is safe to fill the
 	// Ways channel.
@@ -66,36 +82,108 @@ type Config struct {
 	// Concurrency specifies how many concurrent parsers are started. Defaults
 	// to runtime.NumCPU if <= 0.
 	Concurrency int
+
+	// Ordered specifies whether elements should be sent in the order of the
+	// input file. Blocks are still decoded concurrently, but a decoded block
+	// is only sent after all previous blocks were sent. At most
+	// 2*Concurrency blocks are buffered for re-sequencing.
+	Ordered bool
+
+	// OnBlockError defines an optional func that gets called when a block
+	// could not be decoded (e.g. corrupt compression or protobuf data).
+	// Parsing is aborted with the returned error. The block is skipped if
+	// OnBlockError returns nil. By default, the BlockError is returned from
+	// Parse.
+	//
+	// OnBlockError is called concurrently from multiple goroutines.
+	OnBlockError func(err *BlockError) error
+
+	// RecycleBatches enables the reuse of batches that are handed back with
+	// RecycleNodes, RecycleWays and RecycleRelations. The parser overwrites
+	// the elements of recycled batches in-place, including the Tags maps,
+	// Refs, Members and Metadata. This reduces the allocations and the GC
+	// load for large files.
+	//
+	// Consumers should only recycle batches that they no longer use, and
+	// they should not keep references to any part of a recycled batch.
+	RecycleBatches bool
+}
+
+// BlockError describes an error in a single block of the PBF file.
+type BlockError struct {
+	// Index of the block in the file. The header block has index 0, the
+	// first data block index 1.
+	Index int
+	// Offset of the block in the file in bytes.
+	Offset int64
+	// Err is the actual error.
+	Err error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("block #%d at offset %d: %s", e.Index, e.Offset, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
 }
 
 type Parser struct {
-	conf    Config
-	r       io.Reader
-	header  *Header
+	conf   Config
+	r      *countingReader
+	header *Header
+	// index of the next block in the file
+	index int
+	// seeker and entries are only set for parsers created with OpenIndexed
+	seeker  io.ReadSeeker
+	entries []IndexEntry
 	wg      sync.WaitGroup
 	waySync *barrier
 	relSync *barrier
 	err     error
+	// handlerTypes are the element types required by conf.Handler
+	handlerTypes ElementType
+
+	// recycled batches, only set if RecycleBatches is enabled
+	recycledNodes     chan []osm.Node
+	recycledWays      chan []osm.Way
+	recycledRelations chan []osm.Relation
 }
 
 // New creates a new PBF parser for the provided input. Config specifies the destinations for the parsed elements.
 func New(r io.Reader, conf Config) *Parser {
 	p := &Parser{
-		r:    r,
+		r:    &countingReader{r: r},
 		conf: conf,
+		// header is the first block
+		index: 1,
 	}
 
 	if conf.Concurrency <= 0 {
 		p.conf.Concurrency = runtime.NumCPU()
 	}
 
+	p.handlerTypes = handles(conf.Handler)
+
+	if conf.RecycleBatches {
+		// coords and nodes are recycled into the same channel
+		p.recycledNodes = make(chan []osm.Node, 4*p.conf.Concurrency)
+		p.recycledWays = make(chan []osm.Way, 2*p.conf.Concurrency)
+		p.recycledRelations = make(chan []osm.Relation, 2*p.conf.Concurrency)
+	}
+
+	// all elements are sent by a single goroutine in Ordered mode
+	senders := p.conf.Concurrency
+	if conf.Ordered {
+		senders = 1
+	}
 	if conf.OnFirstWay != nil {
 		p.waySync = newBarrier(conf.OnFirstWay)
-		p.waySync.add(p.conf.Concurrency)
+		p.waySync.add(senders)
 	}
 	if conf.OnFirstRelation != nil {
 		p.relSync = newBarrier(conf.OnFirstRelation)
-		p.relSync.add(p.conf.Concurrency)
+		p.relSync.add(senders)
 	}
 	return p
 }
@@ -124,7 +212,7 @@ func (p *Parser) Error() error {
 // Context can be used to cancel the parsing.
 func (p *Parser) Parse(ctx context.Context) (err error) {
 	if p.err != nil {
-		return err
+		return p.err
 	}
 
 	defer func() {
@@ -137,49 +225,113 @@ func (p *Parser) Parse(ctx context.Context) (err error) {
 			return err
 		}
 	}
+
+	// workerCtx is canceled on the first error from our workers
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var workerErr error
+	var errOnce sync.Once
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			workerErr = err
+			cancel()
+		})
+	}
+	handleBlockErr := func(block rawBlock, err error) {
+		bErr := &BlockError{Index: block.index, Offset: block.offset, Err: err}
+		if p.conf.OnBlockError == nil {
+			setErr(bErr)
+		} else if err := p.conf.OnBlockError(bErr); err != nil {
+			setErr(err)
+		}
+	}
+
 	wg := sync.WaitGroup{}
-	blocks := make(chan []byte)
+	blocks := make(chan rawBlock)
+
+	// window limits the number of blocks that are buffered for re-sequencing
+	// in Ordered mode
+	var window chan struct{}
+	var decoded chan decodedBlock
+	var sequencerDone chan struct{}
+
+	if p.conf.Ordered {
+		window = make(chan struct{}, 2*p.conf.Concurrency)
+		decoded = make(chan decodedBlock, p.conf.Concurrency)
+		sequencerDone = make(chan struct{})
+		go func() {
+			p.sequenceBlocks(workerCtx, decoded, window, handleBlockErr, setErr)
+			close(sequencerDone)
+		}()
+	}
 
 	for i := 0; i < p.conf.Concurrency; i++ {
 		wg.Add(1)
 		go func() {
+			d := newDecoder(&p.conf, p.header.HistoricalInformation)
 			for block := range blocks {
-				p.parseBlock(block)
-			}
-			if p.waySync != nil {
-				p.waySync.doneWait()
+				if workerCtx.Err() != nil {
+					// drain remaining blocks after an error
+					if p.conf.Ordered {
+						<-window
+					}
+					continue
+				}
+				batches, err := p.decodeBlock(d, block.data)
+				putBuf(block.data)
+				if p.conf.Ordered {
+					decoded <- decodedBlock{rawBlock: block, batches: batches, err: err}
+					continue
+				}
+				if err != nil {
+					handleBlockErr(block, err)
+					continue
+				}
+				if err := p.sendBatches(workerCtx, batches); err != nil {
+					setErr(err)
+				}
 			}
-			if p.relSync != nil {
-				p.relSync.doneWait()
+			if !p.conf.Ordered {
+				p.syncDone()
 			}
 			wg.Done()
 		}()
 	}
 
 read:
-	for {
-		header, data, err := nextBlock(p.r)
+	for seq := 0; ; seq++ {
+		block, err := p.readBlock()
 		if err == io.EOF {
 			break read
 		}
 		if err != nil {
-			close(blocks)
-			return fmt.Errorf("parsing next block: %w", err)
+			setErr(err)
+			break read
 		}
-		if header.GetType() != "OSMData" {
-			close(blocks)
-			return errors.New("next block not of type OSMData but " + header.GetType())
+		block.seq = seq
+		if p.conf.Ordered {
+			select {
+			case <-workerCtx.Done():
+				break read
+			case window <- struct{}{}:
+			}
 		}
 		select {
-		case <-ctx.Done():
-			fmt.Println("done")
+		case <-workerCtx.Done():
+			if p.conf.Ordered {
+				<-window
+			}
 			break read
-		case blocks <- data:
+		case blocks <- block:
 		}
 	}
 
 	close(blocks)
 	wg.Wait()
+	if p.conf.Ordered {
+		close(decoded)
+		<-sequencerDone
+	}
 
 	if !p.conf.KeepOpen {
 		if p.conf.Coords != nil {
@@ -196,9 +348,122 @@ read:
 		}
 	}
 
+	if workerErr != nil {
+		return workerErr
+	}
 	return ctx.Err()
 }
 
+// readBlock reads the next data block from the file, or from the next
+// matching index entry for parsers created with OpenIndexed.
+func (p *Parser) readBlock() (rawBlock, error) {
+	// expected size of the block from the index, zero if unknown
+	var size int64
+	if p.seeker != nil {
+		if len(p.entries) == 0 {
+			return rawBlock{}, io.EOF
+		}
+		entry := p.entries[0]
+		p.entries = p.entries[1:]
+		if _, err := p.seeker.Seek(entry.Offset, io.SeekStart); err != nil {
+			return rawBlock{}, &BlockError{Index: entry.Index, Offset: entry.Offset, Err: fmt.Errorf("seeking to block: %w", err)}
+		}
+		p.r.n = entry.Offset
+		p.index = entry.Index
+		size = entry.Size
+	}
+
+	index := p.index
+	offset := p.r.n
+	p.index++
+	header, data, err := nextBlock(p.r)
+	if err == io.EOF {
+		return rawBlock{}, err
+	}
+	if err != nil {
+		return rawBlock{}, &BlockError{Index: index, Offset: offset, Err: fmt.Errorf("parsing next block: %w", err)}
+	}
+	if header.GetType() != "OSMData" {
+		return rawBlock{}, &BlockError{Index: index, Offset: offset, Err: errors.New("next block not of type OSMData but " + header.GetType())}
+	}
+	if size != 0 && p.r.n-offset != size {
+		putBuf(data)
+		return rawBlock{}, &BlockError{Index: index, Offset: offset, Err: fmt.Errorf("block size %d differs from size %d in index", p.r.n-offset, size)}
+	}
+	return rawBlock{index: index, offset: offset, data: data}, nil
+}
+
+// rawBlock is a single encoded block of the PBF file.
+type rawBlock struct {
+	// seq is the position in which the block was read, index the position
+	// in the file. They differ for parsers created with OpenIndexed.
+	seq    int
+	index  int
+	offset int64
+	data   []byte
+}
+
+// decodedBlock is a rawBlock with the parsed elements.
+type decodedBlock struct {
+	rawBlock
+	batches []batch
+	err     error
+}
+
+// batch contains the parsed elements from a single PrimitiveGroup.
+type batch struct {
+	coords    []osm.Node
+	nodes     []osm.Node
+	ways      []osm.Way
+	relations []osm.Relation
+}
+
+// sequenceBlocks sends all decoded blocks in the order of the input file.
+// Blocks arrive in arbitrary order and are buffered till all previous blocks
+// are sent. Releases a slot in window for each block.
+func (p *Parser) sequenceBlocks(
+	ctx context.Context,
+	decoded <-chan decodedBlock,
+	window <-chan struct{},
+	handleBlockErr func(rawBlock, error),
+	setErr func(error),
+) {
+	pending := make(map[int]decodedBlock)
+	next := 0
+	for block := range decoded {
+		pending[block.seq] = block
+		for {
+			block, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if ctx.Err() == nil {
+				if block.err != nil {
+					handleBlockErr(block.rawBlock, block.err)
+				} else if err := p.sendBatches(ctx, block.batches); err != nil {
+					setErr(err)
+				}
+			}
+			<-window
+		}
+	}
+	p.syncDone()
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
 func (p *Parser) parseHeader() error {
 	if p.header != nil {
 		return nil
@@ -208,54 +473,181 @@ func (p *Parser) parseHeader() error {
 	return err
 }
 
-func (p *Parser) parseBlock(blob []byte) error {
-	block, err := decodePrimitiveBlock(blob)
+// syncDone marks that the calling goroutine does not send any more elements
+// for the OnFirstWay/OnFirstRelation synchronization.
+func (p *Parser) syncDone() {
+	if p.waySync != nil {
+		p.waySync.doneWait()
+	}
+	if p.relSync != nil {
+		p.relSync.doneWait()
+	}
+}
+
+// decodeBlock decodes the PrimitiveBlock and returns the parsed elements for
+// each PrimitiveGroup. Panics from invalid blocks (e.g. out of range string
+// table indices) are returned as error.
+func (p *Parser) decodeBlock(d *decoder, blob []byte) (batches []batch, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			batches = nil
+			err = fmt.Errorf("decoding invalid block: %v", r)
+		}
+	}()
+	block, err := decodePrimitiveBlock(blob, &d.blockBuf)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	stringtable := newStringTable(block.GetStringtable())
+	d.reset(block)
 
+	batches = make([]batch, 0, len(block.Primitivegroup))
 	for _, group := range block.Primitivegroup {
-		if p.conf.Coords != nil || p.conf.Nodes != nil {
+		var b batch
+		if (p.conf.Coords != nil || p.conf.Nodes != nil || p.handlerTypes&NodeType != 0) && p.conf.Filter.includes(NodeType) {
 			dense := group.GetDense()
 			if dense != nil {
-				parsedCoords, parsedNodes := readDenseNodes(dense, block, stringtable, p.conf.Coords == nil, p.conf.IncludeMetadata)
-				if len(parsedCoords) > 0 && p.conf.Coords != nil {
-					p.conf.Coords <- parsedCoords
-				}
-				if len(parsedNodes) > 0 && p.conf.Nodes != nil {
-					p.conf.Nodes <- parsedNodes
-				}
+				b.coords, b.nodes = d.readDenseNodes(dense, p.recycledNodeBatch(), p.recycledNodeBatch())
 			}
 			if len(group.Nodes) > 0 {
-				parsedCoords, parsedNodes := readNodes(group.Nodes, block, stringtable, p.conf.Coords == nil, p.conf.IncludeMetadata)
-				if len(parsedCoords) > 0 && p.conf.Coords != nil {
-					p.conf.Coords <- parsedCoords
+				b.coords, b.nodes = d.readNodes(group.Nodes, p.recycledNodeBatch(), p.recycledNodeBatch())
+			}
+		}
+		if len(group.Ways) > 0 && (p.conf.Ways != nil || p.handlerTypes&WayType != 0) && p.conf.Filter.includes(WayType) {
+			b.ways = d.readWays(group.Ways, p.recycledWayBatch())
+		}
+		if len(group.Relations) > 0 && (p.conf.Relations != nil || p.handlerTypes&RelationType != 0) && p.conf.Filter.includes(RelationType) {
+			b.relations = d.readRelations(group.Relations, p.recycledRelationBatch())
+		}
+		batches = append(batches, b)
+	}
+	return batches, nil
+}
+
+// RecycleNodes hands a batch of nodes or coords back to the parser. The
+// batch is reused for the next parsed nodes if Config.RecycleBatches is
+// enabled. The batch and its elements must not be used after this call.
+func (p *Parser) RecycleNodes(nodes []osm.Node) {
+	select {
+	case p.recycledNodes <- nodes:
+	default:
+		// not enabled (nil channel) or enough recycled batches
+	}
+}
+
+// RecycleWays hands a batch of ways back to the parser. See RecycleNodes.
+func (p *Parser) RecycleWays(ways []osm.Way) {
+	select {
+	case p.recycledWays <- ways:
+	default:
+	}
+}
+
+// RecycleRelations hands a batch of relations back to the parser. See
+// RecycleNodes.
+func (p *Parser) RecycleRelations(relations []osm.Relation) {
+	select {
+	case p.recycledRelations <- relations:
+	default:
+	}
+}
+
+func (p *Parser) recycledNodeBatch() []osm.Node {
+	select {
+	case nodes := <-p.recycledNodes:
+		return nodes
+	default:
+		return nil
+	}
+}
+
+func (p *Parser) recycledWayBatch() []osm.Way {
+	select {
+	case ways := <-p.recycledWays:
+		return ways
+	default:
+		return nil
+	}
+}
+
+func (p *Parser) recycledRelationBatch() []osm.Relation {
+	select {
+	case relations := <-p.recycledRelations:
+		return relations
+	default:
+		return nil
+	}
+}
+
+// sendBatches passes all parsed elements to the Handler and sends them to
+// the destination channels. Returns the first error from the Handler, or
+// ctx.Err() if ctx is canceled while waiting for a receiver.
+func (p *Parser) sendBatches(ctx context.Context, batches []batch) error {
+	h := p.conf.Handler
+	for _, b := range batches {
+		if len(b.coords) > 0 {
+			if p.conf.Coords != nil {
+				if err := stream.Send(ctx, p.conf.Coords, b.coords); err != nil {
+					return err
 				}
-				if len(parsedNodes) > 0 && p.conf.Nodes != nil {
-					p.conf.Nodes <- parsedNodes
+			} else {
+				p.RecycleNodes(b.coords)
+			}
+		}
+		if len(b.nodes) > 0 {
+			if p.handlerTypes&NodeType != 0 {
+				for i := range b.nodes {
+					if err := h.Node(&b.nodes[i]); err != nil {
+						return err
+					}
+				}
+			}
+			if p.conf.Nodes != nil {
+				if err := stream.Send(ctx, p.conf.Nodes, b.nodes); err != nil {
+					return err
 				}
+			} else {
+				p.RecycleNodes(b.nodes)
 			}
 		}
-		if len(group.Ways) > 0 && p.conf.Ways != nil {
-			parsedWays := readWays(group.Ways, block, stringtable, p.conf.IncludeMetadata)
-			if len(parsedWays) > 0 {
-				if p.waySync != nil {
-					p.waySync.doneWait()
+		if len(b.ways) > 0 {
+			if p.waySync != nil {
+				p.waySync.doneWait()
+			}
+			if p.handlerTypes&WayType != 0 {
+				for i := range b.ways {
+					if err := h.Way(&b.ways[i]); err != nil {
+						return err
+					}
+				}
+			}
+			if p.conf.Ways != nil {
+				if err := stream.Send(ctx, p.conf.Ways, b.ways); err != nil {
+					return err
 				}
-				p.conf.Ways <- parsedWays
+			} else {
+				p.RecycleWays(b.ways)
 			}
 		}
-		if len(group.Relations) > 0 && p.conf.Relations != nil {
-			parsedRelations := readRelations(group.Relations, block, stringtable, p.conf.IncludeMetadata)
-			if len(parsedRelations) > 0 {
-				if p.waySync != nil {
-					p.waySync.doneWait()
+		if len(b.relations) > 0 {
+			if p.waySync != nil {
+				p.waySync.doneWait()
+			}
+			if p.relSync != nil {
+				p.relSync.doneWait()
+			}
+			if p.handlerTypes&RelationType != 0 {
+				for i := range b.relations {
+					if err := h.Relation(&b.relations[i]); err != nil {
+						return err
+					}
 				}
-				if p.relSync != nil {
-					p.relSync.doneWait()
+			}
+			if p.conf.Relations != nil {
+				if err := stream.Send(ctx, p.conf.Relations, b.relations); err != nil {
+					return err
 				}
-				p.conf.Relations <- parsedRelations
+			} else {
+				p.RecycleRelations(b.relations)
 			}
 		}
 	}
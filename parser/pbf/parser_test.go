@@ -1,14 +1,24 @@
 package pbf
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
+	"math"
 	"os"
 	"reflect"
+	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/pbf/internal/osmpbf"
 )
 
 func TestParser(t *testing.T) {
@@ -29,6 +39,107 @@ func BenchmarkParser_IncludeMetadata(b *testing.B) {
 	}
 }
 
+// BenchmarkParser_Allocs reports the allocations per parsed element, with
+// and without recycled batches.
+func BenchmarkParser_Allocs(b *testing.B) {
+	data, err := os.ReadFile("./monaco-20150428.osm.pbf")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, bc := range []struct {
+		name      string
+		includeMD bool
+		recycle   bool
+	}{
+		{"Default", false, false},
+		{"IncludeMetadata", true, false},
+		{"RecycleBatches", false, true},
+		{"RecycleBatches_IncludeMetadata", true, true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var elems int64
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				elems += parseRecycled(b, bytes.NewReader(data), bc.includeMD, bc.recycle, nil)
+			}
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(elems), "allocs/elem")
+			b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/float64(elems), "B/elem")
+		})
+	}
+}
+
+// parseRecycled parses r and recycles all batches after they were passed
+// to collect (if not nil). Returns the number of parsed coords, ways and
+// relations.
+func parseRecycled(t testing.TB, r io.Reader, includeMD, recycle bool, collect func(nodes []osm.Node, ways []osm.Way, rels []osm.Relation)) int64 {
+	conf := Config{
+		IncludeMetadata: includeMD,
+		RecycleBatches:  recycle,
+		Coords:          make(chan []osm.Node),
+		Nodes:           make(chan []osm.Node),
+		Ways:            make(chan []osm.Way),
+		Relations:       make(chan []osm.Relation),
+	}
+	p := New(r, conf)
+
+	var mu sync.Mutex
+	var num int64
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	go func() {
+		for nds := range conf.Coords {
+			atomic.AddInt64(&num, int64(len(nds)))
+			p.RecycleNodes(nds)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for nds := range conf.Nodes {
+			if collect != nil {
+				mu.Lock()
+				collect(nds, nil, nil)
+				mu.Unlock()
+			}
+			p.RecycleNodes(nds)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for ws := range conf.Ways {
+			atomic.AddInt64(&num, int64(len(ws)))
+			if collect != nil {
+				mu.Lock()
+				collect(nil, ws, nil)
+				mu.Unlock()
+			}
+			p.RecycleWays(ws)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for rs := range conf.Relations {
+			atomic.AddInt64(&num, int64(len(rs)))
+			if collect != nil {
+				mu.Lock()
+				collect(nil, nil, rs)
+				mu.Unlock()
+			}
+			p.RecycleRelations(rs)
+		}
+		wg.Done()
+	}()
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	return num
+}
+
 func checkParser(t testing.TB, includeMD bool) {
 	conf := Config{
 		Coords:          make(chan []osm.Node),
@@ -297,11 +408,79 @@ func TestParserNotify(t *testing.T) {
 }
 
 func TestParseCancel(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		conf := Config{
+			Nodes:       make(chan []osm.Node),
+			Ways:        make(chan []osm.Way),
+			Relations:   make(chan []osm.Relation),
+			Concurrency: 1,
+			Ordered:     ordered,
+		}
+
+		f, err := os.Open("./monaco-20150428.osm.pbf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		p := New(f, conf)
+
+		wg := sync.WaitGroup{}
+		ctx, stop := context.WithCancel(context.Background())
+		var numNodes, numWays, numRelations int64
+
+		wg.Add(3)
+		go func() {
+			for nd := range conf.Nodes {
+				numNodes += int64(len(nd))
+				// stop after first parsed nodes
+				stop()
+			}
+			wg.Done()
+		}()
+		go func() {
+			for ways := range conf.Ways {
+				numWays += int64(len(ways))
+			}
+			wg.Done()
+		}()
+		go func() {
+			for rels := range conf.Relations {
+				numRelations += int64(len(rels))
+			}
+			wg.Done()
+		}()
+
+		err = p.Parse(ctx)
+		if err != context.Canceled {
+			t.Fatal(err)
+		}
+		wg.Wait()
+
+		// the first block of 8k nodes is parsed before everything is
+		// stop()ed, the block in progress can still be sent
+		if numNodes < 8000 || numNodes > 16000 {
+			t.Error("parsed an unexpected number of nodes:", numNodes)
+		}
+		if numWays != 0 {
+			t.Error("parsed an unexpected number of ways:", numWays)
+		}
+		if numRelations != 0 {
+			t.Error("parsed an unexpected number of relations:", numRelations)
+		}
+		if p.Error() != context.Canceled {
+			t.Error("expected context.Canceled from Error, got", p.Error())
+		}
+	}
+}
+
+func TestParseCancelWithoutReceiver(t *testing.T) {
 	conf := Config{
+		Coords:      make(chan []osm.Node),
 		Nodes:       make(chan []osm.Node),
 		Ways:        make(chan []osm.Way),
 		Relations:   make(chan []osm.Relation),
-		Concurrency: 1,
+		Concurrency: 4,
 	}
 
 	f, err := os.Open("./monaco-20150428.osm.pbf")
@@ -310,51 +489,34 @@ func TestParseCancel(t *testing.T) {
 	}
 	defer f.Close()
 
-	p := New(f, conf)
-
-	wg := sync.WaitGroup{}
 	ctx, stop := context.WithCancel(context.Background())
-	var numNodes, numWays, numRelations int64
-
 	go func() {
-		wg.Add(1)
-		for nd := range conf.Nodes {
-			numNodes += int64(len(nd))
-			// stop after first parsed nodes
-			stop()
-		}
-		wg.Done()
+		// stop reading after the first batch
+		<-conf.Coords
+		stop()
 	}()
+
+	errc := make(chan error)
 	go func() {
-		wg.Add(1)
-		for ways := range conf.Ways {
-			numWays += int64(len(ways))
-		}
-		wg.Done()
+		errc <- New(f, conf).Parse(ctx)
 	}()
-	go func() {
-		wg.Add(1)
-		for rels := range conf.Relations {
-			numRelations += int64(len(rels))
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Fatal("expected context.Canceled, got", err)
 		}
-		wg.Done()
-	}()
-
-	err = p.Parse(ctx)
-	if err != context.Canceled {
-		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Parse did not return after cancel")
 	}
-	wg.Wait()
 
-	// only two blocks of 8k nodes should be parsed before everything is stop()ed
-	if numNodes != 16000 {
-		t.Error("parsed an unexpected number of nodes:", numNodes)
+	// all channels are closed
+	for range conf.Coords {
 	}
-	if numWays != 0 {
-		t.Error("parsed an unexpected number of ways:", numWays)
+	for range conf.Nodes {
 	}
-	if numRelations != 0 {
-		t.Error("parsed an unexpected number of relations:", numRelations)
+	for range conf.Ways {
+	}
+	for range conf.Relations {
 	}
 }
 
@@ -424,6 +586,7 @@ func TestParseMetadata(t *testing.T) {
 						Version:   5,
 						Timestamp: time.Unix(1335970231, 0),
 						Changeset: 11480240,
+						Visible:   true,
 					},
 				},
 				Lat:  43.737012500000006,
@@ -441,6 +604,7 @@ func TestParseMetadata(t *testing.T) {
 						Version:   8,
 						Timestamp: time.Unix(1335884779, 0),
 						Changeset: 11470653,
+						Visible:   true,
 					},
 				},
 				Lat:  43.737239900000006,
@@ -468,6 +632,7 @@ func TestParseMetadata(t *testing.T) {
 						Version:   7,
 						Timestamp: time.Unix(1417551724, 0),
 						Changeset: 27187519,
+						Visible:   true,
 					},
 				},
 				Refs: []int64{21912089, 1079750744, 2104793864, 1110560507, 21912093, 21912095, 1079751630, 21912097, 21912099},
@@ -484,6 +649,7 @@ func TestParseMetadata(t *testing.T) {
 						Version:   9,
 						Timestamp: time.Unix(1368522546, 0),
 						Changeset: 16122419,
+						Visible:   true,
 					},
 				},
 				Refs: []int64{25177418, 25177397},
@@ -510,6 +676,7 @@ func TestParseMetadata(t *testing.T) {
 						Version:   2,
 						Timestamp: time.Unix(1298228849, 0),
 						Changeset: 7346501,
+						Visible:   true,
 					},
 				},
 				Members: []osm.Member{
@@ -524,3 +691,561 @@ func TestParseMetadata(t *testing.T) {
 		}
 	}
 }
+
+// corruptBlock returns the content of the monaco PBF with a corrupt data
+// section in the block with the given index. Also returns the offset of the
+// block.
+func corruptBlock(t *testing.T, index int) ([]byte, int64) {
+	data, err := os.ReadFile("./monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &countingReader{r: bytes.NewReader(data)}
+	for i := 0; ; i++ {
+		offset := r.n
+		header, err := nextBlobHeader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == index {
+			// overwrite the end of the compressed data
+			end := r.n + int64(header.GetDatasize())
+			for j := end - 64; j < end; j++ {
+				data[j] = 0xff
+			}
+			return data, offset
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(header.GetDatasize())); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseBlockError(t *testing.T) {
+	data, offset := corruptBlock(t, 2)
+
+	conf := Config{
+		Nodes:     make(chan []osm.Node),
+		Ways:      make(chan []osm.Way),
+		Relations: make(chan []osm.Relation),
+	}
+	p := New(bytes.NewReader(data), conf)
+
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go func() {
+		for range conf.Nodes {
+		}
+		wg.Done()
+	}()
+	go func() {
+		for range conf.Ways {
+		}
+		wg.Done()
+	}()
+	go func() {
+		for range conf.Relations {
+		}
+		wg.Done()
+	}()
+
+	err := p.Parse(context.Background())
+	wg.Wait()
+
+	var bErr *BlockError
+	if !errors.As(err, &bErr) {
+		t.Fatal("expected BlockError, got", err)
+	}
+	if bErr.Index != 2 || bErr.Offset != offset {
+		t.Errorf("unexpected block in error %#v, expected offset %d", bErr, offset)
+	}
+	if p.Error() != err {
+		t.Error("expected same error from Error()", p.Error())
+	}
+}
+
+func TestParseOnBlockError(t *testing.T) {
+	data, _ := corruptBlock(t, 2)
+
+	var numErrors int32
+	conf := Config{
+		Nodes: make(chan []osm.Node),
+		OnBlockError: func(err *BlockError) error {
+			atomic.AddInt32(&numErrors, 1)
+			return nil
+		},
+	}
+	p := New(bytes.NewReader(data), conf)
+
+	var numNodes int64
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		for nds := range conf.Nodes {
+			numNodes += int64(len(nds))
+		}
+		wg.Done()
+	}()
+
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if numErrors != 1 {
+		t.Error("unexpected number of errors:", numErrors)
+	}
+	// second block with 8000 nodes is skipped
+	if numNodes != 17233-8000 {
+		t.Error("parsed an unexpected number of nodes:", numNodes)
+	}
+}
+
+func TestParseInvalidStringIndex(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{})
+	if err := w.writeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	block, err := proto.Marshal(&osmpbf.PrimitiveBlock{
+		Stringtable: &osmpbf.StringTable{S: [][]byte{{}}},
+		Primitivegroup: []*osmpbf.PrimitiveGroup{{
+			// tag with out of range string indices
+			Dense: &osmpbf.DenseNodes{Id: []int64{1}, Lat: []int64{0}, Lon: []int64{0}, KeysVals: []int32{5, 6, 0}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := int64(buf.Len())
+	if err := w.writeBlob("OSMData", block); err != nil {
+		t.Fatal(err)
+	}
+
+	var blockErr *BlockError
+	conf := Config{
+		Nodes: make(chan []osm.Node),
+		OnBlockError: func(err *BlockError) error {
+			blockErr = err
+			return nil
+		},
+	}
+	go func() {
+		for range conf.Nodes {
+		}
+	}()
+	if err := New(buf, conf).Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if blockErr == nil || blockErr.Index != 1 || blockErr.Offset != offset {
+		t.Errorf("unexpected block error %#v, expected offset %d", blockErr, offset)
+	}
+}
+
+func TestParseMetadataWithoutDenseInfo(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{})
+	nodes := []osm.Node{
+		{Element: osm.Element{ID: 1, Tags: osm.Tags{"name": "foo"}}, Lat: 53, Long: 8},
+		{Element: osm.Element{ID: 2, Tags: osm.Tags{"name": "bar"}}, Lat: 54, Long: 9},
+	}
+	if err := w.WriteNodes(nodes); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{IncludeMetadata: true, Nodes: make(chan []osm.Node, 1)}
+	if err := New(buf, conf).Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var got []osm.Node
+	for nds := range conf.Nodes {
+		got = append(got, nds...)
+	}
+	if !reflect.DeepEqual(got, nodes) {
+		t.Errorf("unexpected nodes %#v", got)
+	}
+}
+
+func TestParseHistoryCoords(t *testing.T) {
+	// history file with deleted dense (2) and non-dense (5) nodes
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{Header: Header{HistoricalInformation: true}})
+	if err := w.writeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	info := func(visible bool) osmpbf.Info {
+		return osmpbf.Info{Version: proto.Int32(1), Visible: visible}
+	}
+	for _, group := range []*osmpbf.PrimitiveGroup{
+		{Dense: &osmpbf.DenseNodes{
+			Id:  []int64{1, 1, 1},
+			Lat: []int64{530000000, 0, -530000000},
+			Lon: []int64{80000000, 0, -80000000},
+			Denseinfo: &osmpbf.DenseInfo{
+				Version:   []int32{1, 2, 1},
+				Timestamp: []int64{0, 0, 0},
+				Changeset: []int64{0, 0, 0},
+				Uid:       []int32{0, 0, 0},
+				UserSid:   []int32{0, 0, 0},
+				Visible:   []bool{true, false, true},
+			},
+		}},
+		{Nodes: []osmpbf.Node{
+			{Id: 4, Lat: 540000000, Lon: 90000000, Info: info(true)},
+			{Id: 5, Info: info(false)},
+		}},
+	} {
+		block, err := proto.Marshal(&osmpbf.PrimitiveBlock{
+			Stringtable:    &osmpbf.StringTable{S: [][]byte{{}}},
+			Primitivegroup: []*osmpbf.PrimitiveGroup{group},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.writeBlob("OSMData", block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+
+	for _, includeMD := range []bool{false, true} {
+		conf := Config{
+			IncludeMetadata: includeMD,
+			Coords:          make(chan []osm.Node),
+			Nodes:           make(chan []osm.Node),
+			Concurrency:     1,
+		}
+		var coords, nodes []int64
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+		go func() {
+			for nds := range conf.Coords {
+				for _, nd := range nds {
+					coords = append(coords, nd.ID)
+				}
+			}
+			wg.Done()
+		}()
+		go func() {
+			for nds := range conf.Nodes {
+				for _, nd := range nds {
+					nodes = append(nodes, nd.ID)
+				}
+			}
+			wg.Done()
+		}()
+		if err := New(bytes.NewReader(data), conf).Parse(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+
+		if !reflect.DeepEqual(coords, []int64{1, 3, 4}) {
+			t.Errorf("unexpected coords %v with metadata %v", coords, includeMD)
+		}
+		// deleted nodes are only sent to Nodes with metadata
+		wantNodes := []int64(nil)
+		if includeMD {
+			wantNodes = []int64{2, 5}
+		}
+		if !reflect.DeepEqual(nodes, wantNodes) {
+			t.Errorf("unexpected nodes %v with metadata %v", nodes, includeMD)
+		}
+	}
+}
+
+func TestParseOrdered(t *testing.T) {
+	collectIDs := func(conf Config) (nodes, ways, rels []int64) {
+		conf.Nodes = make(chan []osm.Node)
+		conf.Ways = make(chan []osm.Way)
+		conf.Relations = make(chan []osm.Relation)
+
+		f, err := os.Open("./monaco-20150428.osm.pbf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		p := New(f, conf)
+
+		wg := sync.WaitGroup{}
+		wg.Add(3)
+		go func() {
+			for nds := range conf.Nodes {
+				for _, nd := range nds {
+					nodes = append(nodes, nd.ID)
+				}
+			}
+			wg.Done()
+		}()
+		go func() {
+			for ws := range conf.Ways {
+				for _, w := range ws {
+					ways = append(ways, w.ID)
+				}
+			}
+			wg.Done()
+		}()
+		go func() {
+			for rs := range conf.Relations {
+				for _, r := range rs {
+					rels = append(rels, r.ID)
+				}
+			}
+			wg.Done()
+		}()
+
+		if err := p.Parse(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		return nodes, ways, rels
+	}
+
+	wantNodes, wantWays, wantRels := collectIDs(Config{Concurrency: 1})
+	for i := 0; i < 5; i++ {
+		nodes, ways, rels := collectIDs(Config{Concurrency: 4, Ordered: true})
+		if !reflect.DeepEqual(nodes, wantNodes) {
+			t.Error("nodes not in file order")
+		}
+		if !reflect.DeepEqual(ways, wantWays) {
+			t.Error("ways not in file order")
+		}
+		if !reflect.DeepEqual(rels, wantRels) {
+			t.Error("relations not in file order")
+		}
+	}
+}
+
+func TestParseOrderedBlockError(t *testing.T) {
+	data, _ := corruptBlock(t, 2)
+
+	var errIndices []int
+	conf := Config{
+		Coords:      make(chan []osm.Node),
+		Concurrency: 4,
+		Ordered:     true,
+		OnBlockError: func(err *BlockError) error {
+			errIndices = append(errIndices, err.Index)
+			return nil
+		},
+	}
+	p := New(bytes.NewReader(data), conf)
+
+	var ids []int64
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		for nds := range conf.Coords {
+			ids = append(ids, nds[0].ID)
+		}
+		wg.Done()
+	}()
+
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if !reflect.DeepEqual(errIndices, []int{2}) {
+		t.Error("unexpected errors", errIndices)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] >= ids[i] {
+			t.Error("blocks not in order", ids)
+		}
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	f, err := os.Open("./monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p := New(f, Config{})
+	header, err := p.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Header{
+		Time:             time.Unix(1430166062, 0),
+		BBox:             [4]float64{7.409205, 43.72335, 7.448637, 43.75169},
+		WritingProgram:   "Osmium (http://wiki.openstreetmap.org/wiki/Osmium)",
+		RequiredFeatures: []string{"OsmSchema-V0.6", "DenseNodes"},
+	}
+	for i := range want.BBox {
+		if math.Abs(header.BBox[i]-want.BBox[i]) > 1e-9 {
+			t.Fatalf("unexpected bbox %v", header.BBox)
+		}
+	}
+	header.BBox = want.BBox
+	if !reflect.DeepEqual(header, want) {
+		t.Errorf("unexpected header\n%#v\nwant\n%#v", header, want)
+	}
+}
+
+func TestParseRecycleBatches(t *testing.T) {
+	parse := func(recycle bool) (nodes map[int64]osm.Node, ways map[int64]osm.Way, rels map[int64]osm.Relation) {
+		f, err := os.Open("./monaco-20150428.osm.pbf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		nodes = make(map[int64]osm.Node)
+		ways = make(map[int64]osm.Way)
+		rels = make(map[int64]osm.Relation)
+		// copy all elements, as recycled batches are overwritten
+		copyElement := func(e osm.Element) osm.Element {
+			if e.Tags != nil {
+				tags := make(osm.Tags, len(e.Tags))
+				for k, v := range e.Tags {
+					tags[k] = v
+				}
+				e.Tags = tags
+			}
+			if e.Metadata != nil {
+				md := *e.Metadata
+				e.Metadata = &md
+			}
+			return e
+		}
+		parseRecycled(t, f, true, recycle, func(nds []osm.Node, ws []osm.Way, rs []osm.Relation) {
+			for _, nd := range nds {
+				nd.Element = copyElement(nd.Element)
+				nodes[nd.ID] = nd
+			}
+			for _, w := range ws {
+				w.Element = copyElement(w.Element)
+				w.Refs = append([]int64(nil), w.Refs...)
+				ways[w.ID] = w
+			}
+			for _, r := range rs {
+				r.Element = copyElement(r.Element)
+				r.Members = append([]osm.Member(nil), r.Members...)
+				rels[r.ID] = r
+			}
+		})
+		return nodes, ways, rels
+	}
+
+	wantNodes, wantWays, wantRels := parse(false)
+	gotNodes, gotWays, gotRels := parse(true)
+	if len(wantNodes) != 978 || len(wantWays) != 2398 || len(wantRels) != 108 {
+		t.Fatal("unexpected number of elements", len(wantNodes), len(wantWays), len(wantRels))
+	}
+	if !reflect.DeepEqual(gotNodes, wantNodes) {
+		t.Error("nodes differ with RecycleBatches")
+	}
+	if !reflect.DeepEqual(gotWays, wantWays) {
+		t.Error("ways differ with RecycleBatches")
+	}
+	if !reflect.DeepEqual(gotRels, wantRels) {
+		t.Error("relations differ with RecycleBatches")
+	}
+}
+
+func TestStringInterner(t *testing.T) {
+	si := make(stringInterner)
+	a := si.intern([]byte("highway"))
+	b := si.intern([]byte("highway"))
+	if a != "highway" || unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Error("strings not interned")
+	}
+	for i := 0; i < maxInternedStrings+1; i++ {
+		si.intern([]byte(strconv.Itoa(i)))
+	}
+	if len(si) > maxInternedStrings {
+		t.Error("interner exceeds max size", len(si))
+	}
+}
+
+func TestParseHandler(t *testing.T) {
+	f, err := os.Open("./monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var numNodes, numWays, numRelations, numTagged int64
+	var nodesBeforeWays int64 = -1
+	conf := Config{
+		// tagged nodes are still sent to Nodes
+		Nodes: make(chan []osm.Node),
+		Handler: HandlerFuncs{
+			OnNode: func(nd *osm.Node) error {
+				atomic.AddInt64(&numNodes, 1)
+				return nil
+			},
+			OnWay: func(w *osm.Way) error {
+				atomic.AddInt64(&numWays, 1)
+				return nil
+			},
+			OnRelation: func(r *osm.Relation) error {
+				atomic.AddInt64(&numRelations, 1)
+				return nil
+			},
+		},
+		OnFirstWay: func() {
+			nodesBeforeWays = atomic.LoadInt64(&numNodes)
+		},
+	}
+	p := New(f, conf)
+
+	done := make(chan struct{})
+	go func() {
+		for nds := range conf.Nodes {
+			numTagged += int64(len(nds))
+		}
+		close(done)
+	}()
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if numNodes != 17233 || numWays != 2398 || numRelations != 108 {
+		t.Error("unexpected number of elements", numNodes, numWays, numRelations)
+	}
+	if numTagged != numNodes {
+		t.Error("unexpected number of nodes in channel", numTagged)
+	}
+	if nodesBeforeWays != 17233 {
+		t.Error("unexpected number of nodes before OnFirstWay", nodesBeforeWays)
+	}
+}
+
+func TestParseHandlerError(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		f, err := os.Open("./monaco-20150428.osm.pbf")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errStop := errors.New("stop")
+		var numWays int64
+		conf := Config{
+			Ordered: ordered,
+			Handler: HandlerFuncs{
+				OnWay: func(w *osm.Way) error {
+					if atomic.AddInt64(&numWays, 1) == 10 {
+						return errStop
+					}
+					return nil
+				},
+			},
+		}
+		p := New(f, conf)
+		if err := p.Parse(context.Background()); !errors.Is(err, errStop) {
+			t.Errorf("expected error from handler, got %v (ordered %v)", err, ordered)
+		}
+		if numWays != 10 {
+			t.Errorf("handler called after error: %d (ordered %v)", numWays, ordered)
+		}
+		f.Close()
+	}
+}
@@ -7,12 +7,90 @@ import (
 	"github.com/omniscale/go-osm/parser/pbf/internal/osmpbf"
 )
 
-func readDenseNodes(
+// decoder holds the state of a single decoding goroutine. The string
+// interner and string table are reused for all blocks.
+//
+// Slices and maps of recycled batches (see Config.RecycleBatches) are
+// reused by position: the elements of a recycled batch are overwritten
+// in-place, including their Tags, Refs, Members and Metadata.
+type decoder struct {
+	strings     stringInterner
+	stringtable stringTable
+
+	allNodes   bool
+	includeMD  bool
+	historical bool
+
+	filter  *Filter
+	tagRefs map[string][]tagRef
+	// tags is nil if there is no tags filter
+	tags *tagMatcher
+	// selected contains the indices of the selected elements of a group
+	selected []int
+
+	// metadata is the remaining part of the current metadata slab
+	metadata []osm.Metadata
+
+	block       *osmpbf.PrimitiveBlock
+	blockBuf    blockBuf
+	granularity int64
+	latOffset   int64
+	lonOffset   int64
+}
+
+func newDecoder(conf *Config, historical bool) *decoder {
+	d := &decoder{
+		strings:    make(stringInterner),
+		allNodes:   conf.Coords == nil,
+		includeMD:  conf.IncludeMetadata,
+		historical: historical,
+		filter:     &conf.Filter,
+	}
+	if len(conf.Filter.Tags) > 0 {
+		d.tagRefs = newTagRefs(conf.Filter.Tags)
+		d.tags = &tagMatcher{}
+	}
+	return d
+}
+
+const coordScale = 0.000000001
+
+// reset prepares the decoder for the next block.
+func (d *decoder) reset(block *osmpbf.PrimitiveBlock) {
+	d.block = block
+	d.granularity = int64(block.GetGranularity())
+	d.latOffset = block.GetLatOffset()
+	d.lonOffset = block.GetLonOffset()
+	d.stringtable = newStringTable(block.GetStringtable(), d.stringtable, d.strings)
+	if d.tags != nil {
+		d.tags.reset(d.filter.Tags, d.tagRefs, d.stringtable)
+	}
+}
+
+func (d *decoder) coord(lat, lon int64) (float64, float64) {
+	return coordScale * float64(d.latOffset+(d.granularity*lat)),
+		coordScale * float64(d.lonOffset+(d.granularity*lon))
+}
+
+// newMetadata returns md for reuse, or a new Metadata from the slab. All
+// Metadata of a block are allocated in a few chunks instead of one
+// allocation per element.
+func (d *decoder) newMetadata(md *osm.Metadata) *osm.Metadata {
+	if md != nil {
+		return md
+	}
+	if len(d.metadata) == 0 {
+		d.metadata = make([]osm.Metadata, 256)
+	}
+	md = &d.metadata[0]
+	d.metadata = d.metadata[1:]
+	return md
+}
+
+func (d *decoder) readDenseNodes(
 	dense *osmpbf.DenseNodes,
-	block *osmpbf.PrimitiveBlock,
-	stringtable stringTable,
-	allNodes bool,
-	includeMD bool) (coords []osm.Node, nodes []osm.Node) {
+	coords, nodes []osm.Node,
+) ([]osm.Node, []osm.Node) {
 
 	var lastID int64
 	var lastLon, lastLat int64
@@ -22,48 +100,78 @@ func readDenseNodes(
 	var lastUID int32
 	var lastUserSID int32
 
-	coords = make([]osm.Node, len(dense.Id))
-	if allNodes {
-		nodes = make([]osm.Node, 0, len(dense.Id))
-	} else {
-		// most nodes have no tags
-		nodes = make([]osm.Node, 0, len(dense.Id)/8)
+	coords = reuseNodes(coords, len(dense.Id))[:0]
+	if cap(nodes) == 0 {
+		if d.allNodes {
+			nodes = make([]osm.Node, 0, len(dense.Id))
+		} else {
+			// most nodes have no tags
+			nodes = make([]osm.Node, 0, len(dense.Id)/8)
+		}
 	}
-	granularity := int64(block.GetGranularity())
-	latOffset := block.GetLatOffset()
-	lonOffset := block.GetLonOffset()
-	coordScale := 0.000000001
+	nodes = nodes[:0]
 	lastKeyValPos := 0
+	stringtable := d.stringtable
+	hasTags := stringtable != nil && len(dense.KeysVals) > 0
 
-	var metadata *osm.Metadata
+	var metadata osm.Metadata
+	// DenseInfo is optional, e.g. for files written without metadata
+	includeMD := d.includeMD && dense.Denseinfo != nil
 
-	for i := range coords {
+	for i := range dense.Id {
 		lastID += dense.Id[i]
 		lastLon += dense.Lon[i]
 		lastLat += dense.Lat[i]
-		coords[i].ID = lastID
-		coords[i].Long = (coordScale * float64(lonOffset+(granularity*lastLon)))
-		coords[i].Lat = (coordScale * float64(latOffset+(granularity*lastLat)))
-
 		if includeMD {
 			lastTimestamp += dense.Denseinfo.Timestamp[i]
 			lastChangeset += dense.Denseinfo.Changeset[i]
 			lastUID += dense.Denseinfo.Uid[i]
 			lastUserSID += dense.Denseinfo.UserSid[i]
+		}
+
+		coord := osm.Node{Element: osm.Element{ID: lastID}}
+		coord.Lat, coord.Long = d.coord(lastLat, lastLon)
+		if !d.filter.contains(coord.Lat, coord.Long) {
+			if hasTags {
+				lastKeyValPos = skipDenseTags(dense.KeysVals, lastKeyValPos)
+			}
+			continue
+		}
+		// deleted nodes of historical files have no location
+		deleted := d.historical && dense.Denseinfo != nil &&
+			len(dense.Denseinfo.Visible) > i && !dense.Denseinfo.Visible[i]
+		if !deleted {
+			coords = append(coords, coord)
+		}
 
-			metadata = &osm.Metadata{
+		if hasTags && !d.tags.matchDense(dense.KeysVals, lastKeyValPos) {
+			lastKeyValPos = skipDenseTags(dense.KeysVals, lastKeyValPos)
+			continue
+		}
+
+		if includeMD {
+			metadata = osm.Metadata{
 				Version:   dense.Denseinfo.Version[i],
 				Timestamp: time.Unix(lastTimestamp, 0),
 				Changeset: lastChangeset,
 				UserID:    lastUID,
 				UserName:  stringtable[lastUserSID],
+				Visible:   !deleted,
 			}
 		}
+
+		// the recycled node at the position of the next appended node
+		var prev osm.Node
+		if len(nodes) < cap(nodes) {
+			prev = nodes[:len(nodes)+1][len(nodes)]
+		}
+
 		var tags map[string]string
-		addToNodes := allNodes
-		if stringtable != nil && len(dense.KeysVals) > 0 {
+		// deleted nodes have no tags, but are required for the history
+		addToNodes := d.allNodes || (includeMD && deleted)
+		if hasTags {
 			if dense.KeysVals[lastKeyValPos] != 0 {
-				tags = parseDenseNodeTags(stringtable, &dense.KeysVals, &lastKeyValPos)
+				tags = parseDenseNodeTags(stringtable, &dense.KeysVals, &lastKeyValPos, prev.Tags)
 				if tags != nil {
 					if _, ok := tags["created_by"]; len(tags) > 1 || !ok {
 						// don't add nodes with only created_by tag to nodes
@@ -74,10 +182,13 @@ func readDenseNodes(
 				lastKeyValPos += 1
 			}
 		}
-		if addToNodes {
-			nd := coords[i]
+		if addToNodes && (tags != nil || d.tags == nil) {
+			nd := coord
 			nd.Tags = tags
-			nd.Metadata = metadata
+			if includeMD {
+				nd.Metadata = d.newMetadata(prev.Metadata)
+				*nd.Metadata = metadata
+			}
 			nodes = append(nodes, nd)
 		}
 	}
@@ -85,7 +196,9 @@ func readDenseNodes(
 	return coords, nodes
 }
 
-func parseDenseNodeTags(stringtable stringTable, keysVals *[]int32, pos *int) map[string]string {
+// parseDenseNodeTags parses the tags of the next node from keysVals. The
+// tags are stored in reuse, if not nil.
+func parseDenseNodeTags(stringtable stringTable, keysVals *[]int32, pos *int, reuse map[string]string) map[string]string {
 	// make map later if needed
 	var result map[string]string
 	for {
@@ -100,17 +213,29 @@ func parseDenseNodeTags(stringtable stringTable, keysVals *[]int32, pos *int) ma
 		val := (*keysVals)[*pos]
 		*pos += 1
 		if result == nil {
-			result = make(map[string]string)
+			if reuse != nil {
+				clear(reuse)
+				result = reuse
+			} else {
+				result = make(map[string]string)
+			}
 		}
 		result[stringtable[key]] = stringtable[val]
 	}
 }
 
-func parseTags(stringtable stringTable, keys []uint32, vals []uint32) map[string]string {
+// parseTags returns the tags for keys and vals. The tags are stored in
+// reuse, if not nil.
+func parseTags(stringtable stringTable, keys []uint32, vals []uint32, reuse map[string]string) map[string]string {
 	if len(keys) == 0 {
 		return nil
 	}
-	tags := make(map[string]string, len(keys))
+	tags := reuse
+	if tags != nil {
+		clear(tags)
+	} else {
+		tags = make(map[string]string, len(keys))
+	}
 	for i := 0; i < len(keys); i++ {
 		key := stringtable[keys[i]]
 		val := stringtable[vals[i]]
@@ -119,47 +244,49 @@ func parseTags(stringtable stringTable, keys []uint32, vals []uint32) map[string
 	return tags
 }
 
-func readNodes(
+func (d *decoder) readNodes(
 	nodes []osmpbf.Node,
-	block *osmpbf.PrimitiveBlock,
-	stringtable stringTable,
-	allNodes bool,
-	includeMD bool,
+	coords, nds []osm.Node,
 ) ([]osm.Node, []osm.Node) {
 
-	coords := make([]osm.Node, len(nodes))
-	nds := make([]osm.Node, 0, len(nodes)/8)
-	granularity := int64(block.GetGranularity())
-	latOffset := block.GetLatOffset()
-	lonOffset := block.GetLonOffset()
-	coordScale := 0.000000001
-
-	var metadata *osm.Metadata
+	coords = reuseNodes(coords, len(nodes))[:0]
+	if cap(nds) == 0 {
+		nds = make([]osm.Node, 0, len(nodes)/8)
+	}
+	nds = nds[:0]
 
 	for i := range nodes {
-		id := nodes[i].Id
-		lon := nodes[i].Lon
-		lat := nodes[i].Lat
-		coords[i].ID = id
-		coords[i].Long = (coordScale * float64(lonOffset+(granularity*lon)))
-		coords[i].Lat = (coordScale * float64(latOffset+(granularity*lat)))
+		coord := osm.Node{Element: osm.Element{ID: nodes[i].Id}}
+		coord.Lat, coord.Long = d.coord(nodes[i].Lat, nodes[i].Lon)
+		if !d.filter.contains(coord.Lat, coord.Long) {
+			continue
+		}
+		// deleted nodes of historical files have no location
+		deleted := d.historical && !nodes[i].Info.Visible
+		if !deleted {
+			coords = append(coords, coord)
+		}
+
+		if !d.tags.match(nodes[i].Keys, nodes[i].Vals) {
+			continue
+		}
+
+		// the recycled node at the position of the next appended node
+		var prev osm.Node
+		if len(nds) < cap(nds) {
+			prev = nds[:len(nds)+1][len(nds)]
+		}
+
 		var tags map[string]string
-		addToNodes := allNodes
-		if includeMD {
-			version := int32(0)
-			if nodes[i].Info.Version != nil {
-				version = *nodes[i].Info.Version
-			}
-			metadata = &osm.Metadata{
-				Version:   version,
-				Timestamp: time.Unix(nodes[i].Info.Timestamp, 0),
-				Changeset: nodes[i].Info.Changeset,
-				UserID:    nodes[i].Info.Uid,
-				UserName:  stringtable[nodes[i].Info.UserSid],
-			}
+		var metadata *osm.Metadata
+		addToNodes := d.allNodes
+		if d.includeMD {
+			metadata = d.parseInfo(&nodes[i].Info, prev.Metadata)
+			// deleted nodes have no tags, but are required for the history
+			addToNodes = addToNodes || !metadata.Visible
 		}
-		if stringtable != nil {
-			tags = parseTags(stringtable, nodes[i].Keys, nodes[i].Vals)
+		if d.stringtable != nil {
+			tags = parseTags(d.stringtable, nodes[i].Keys, nodes[i].Vals, prev.Tags)
 			if tags != nil {
 				if _, ok := tags["created_by"]; len(tags) > 1 || !ok {
 					// don't add nodes with only created_by tag to nodes
@@ -168,7 +295,7 @@ func readNodes(
 			}
 		}
 		if addToNodes {
-			nd := coords[i]
+			nd := coord
 			nd.Tags = tags
 			nd.Metadata = metadata
 			nds = append(nds, nd)
@@ -177,8 +304,29 @@ func readNodes(
 	return coords, nds
 }
 
-func parseDeltaRefs(refs []int64) []int64 {
-	result := make([]int64, len(refs))
+// parseInfo returns the metadata for nodes, ways and relations. The visible
+// flag is only evaluated for files with historical information. The
+// metadata is stored in reuse, if not nil.
+func (d *decoder) parseInfo(info *osmpbf.Info, reuse *osm.Metadata) *osm.Metadata {
+	version := int32(0)
+	if info.Version != nil {
+		version = *info.Version
+	}
+	md := d.newMetadata(reuse)
+	*md = osm.Metadata{
+		Version:   version,
+		Timestamp: time.Unix(info.Timestamp, 0),
+		Changeset: info.Changeset,
+		UserID:    info.Uid,
+		UserName:  d.stringtable[info.UserSid],
+		Visible:   !d.historical || info.Visible,
+	}
+	return md
+}
+
+// parseDeltaRefs decodes the delta coded refs into result. result needs to
+// have the same length as refs.
+func parseDeltaRefs(refs []int64, result []int64) []int64 {
 	var lastRef int64
 
 	for i, refDelta := range refs {
@@ -188,89 +336,171 @@ func parseDeltaRefs(refs []int64) []int64 {
 	return result
 }
 
-func readWays(
+// parseWayLocations returns the nodes for the delta coded lat/lon values
+// from files with the LocationsOnWays feature. Returns nil if the number of
+// locations does not match the refs.
+func (d *decoder) parseWayLocations(refs []int64, lats, lons []int64) []osm.Node {
+	if len(lats) != len(refs) || len(lons) != len(refs) {
+		return nil
+	}
+
+	result := make([]osm.Node, len(refs))
+	var lastLat, lastLon int64
+	for i := range refs {
+		lastLat += lats[i]
+		lastLon += lons[i]
+		result[i].ID = refs[i]
+		result[i].Lat, result[i].Long = d.coord(lastLat, lastLon)
+	}
+	return result
+}
+
+func (d *decoder) readWays(
 	ways []osmpbf.Way,
-	block *osmpbf.PrimitiveBlock,
-	stringtable stringTable,
-	includeMD bool,
+	result []osm.Way,
 ) []osm.Way {
 
-	result := make([]osm.Way, len(ways))
-
+	d.selected = d.selected[:0]
 	for i := range ways {
-		id := ways[i].Id
-		result[i].ID = id
-		result[i].Tags = parseTags(stringtable, ways[i].Keys, ways[i].Vals)
-		result[i].Refs = parseDeltaRefs(ways[i].Refs)
-		if includeMD {
-			version := int32(0)
-			if ways[i].Info.Version != nil {
-				version = *ways[i].Info.Version
-			}
-			metadata := &osm.Metadata{
-				Version:   version,
-				Timestamp: time.Unix(ways[i].Info.Timestamp, 0),
-				Changeset: ways[i].Info.Changeset,
-				UserID:    ways[i].Info.Uid,
-				UserName:  stringtable[ways[i].Info.UserSid],
-			}
-			result[i].Metadata = metadata
+		if d.tags.match(ways[i].Keys, ways[i].Vals) {
+			d.selected = append(d.selected, i)
+		}
+	}
+	result = reuseWays(result, len(d.selected))
+
+	// all refs that do not fit into recycled ways are stored in one slab
+	slabSize := 0
+	for i, idx := range d.selected {
+		if result[i].Refs == nil || cap(result[i].Refs) < len(ways[idx].Refs) {
+			slabSize += len(ways[idx].Refs)
+		}
+	}
+	slab := make([]int64, slabSize)
+
+	for i, idx := range d.selected {
+		w := &result[i]
+		way := &ways[idx]
+		w.ID = way.Id
+		w.Tags = parseTags(d.stringtable, way.Keys, way.Vals, w.Tags)
+
+		n := len(way.Refs)
+		refs := w.Refs[:0]
+		if refs == nil || cap(refs) < n {
+			refs, slab = slab[:n:n], slab[n:]
+		}
+		w.Refs = parseDeltaRefs(way.Refs, refs[:n])
+
+		w.Nodes = nil
+		if len(way.Lat) > 0 {
+			w.Nodes = d.parseWayLocations(w.Refs, way.Lat, way.Lon)
+		}
+		if d.includeMD {
+			w.Metadata = d.parseInfo(&way.Info, w.Metadata)
+		} else {
+			w.Metadata = nil
 		}
 	}
 	return result
 }
 
-func parseRelationMembers(rel osmpbf.Relation, stringtable stringTable) []osm.Member {
-	result := make([]osm.Member, len(rel.Memids))
-
+// parseRelationMembers decodes the members of rel into result. result
+// needs to have the same length as the members.
+func parseRelationMembers(rel *osmpbf.Relation, stringtable stringTable, result []osm.Member) []osm.Member {
 	var lastID int64
 	for i := range rel.Memids {
 		lastID += rel.Memids[i]
-		result[i].ID = lastID
-		result[i].Role = stringtable[rel.RolesSid[i]]
-		result[i].Type = osm.MemberType(rel.Types[i])
+		result[i] = osm.Member{
+			ID:   lastID,
+			Role: stringtable[rel.RolesSid[i]],
+			Type: osm.MemberType(rel.Types[i]),
+		}
 	}
 	return result
 }
 
-func readRelations(
+func (d *decoder) readRelations(
 	relations []osmpbf.Relation,
-	block *osmpbf.PrimitiveBlock,
-	stringtable stringTable,
-	includeMD bool,
+	result []osm.Relation,
 ) []osm.Relation {
 
-	result := make([]osm.Relation, len(relations))
-
+	d.selected = d.selected[:0]
 	for i := range relations {
-		id := relations[i].Id
-		result[i].ID = id
-		result[i].Tags = parseTags(stringtable, relations[i].Keys, relations[i].Vals)
-		result[i].Members = parseRelationMembers(relations[i], stringtable)
-		if includeMD {
-			version := int32(0)
-			if relations[i].Info.Version != nil {
-				version = *relations[i].Info.Version
-			}
-			metadata := &osm.Metadata{
-				Version:   version,
-				Timestamp: time.Unix(relations[i].Info.Timestamp, 0),
-				Changeset: relations[i].Info.Changeset,
-				UserID:    relations[i].Info.Uid,
-				UserName:  stringtable[relations[i].Info.UserSid],
-			}
-			result[i].Metadata = metadata
+		if d.tags.match(relations[i].Keys, relations[i].Vals) {
+			d.selected = append(d.selected, i)
+		}
+	}
+	result = reuseRelations(result, len(d.selected))
+
+	// all members that do not fit into recycled relations are stored in
+	// one slab
+	slabSize := 0
+	for i, idx := range d.selected {
+		if result[i].Members == nil || cap(result[i].Members) < len(relations[idx].Memids) {
+			slabSize += len(relations[idx].Memids)
+		}
+	}
+	slab := make([]osm.Member, slabSize)
+
+	for i, idx := range d.selected {
+		r := &result[i]
+		rel := &relations[idx]
+		r.ID = rel.Id
+		r.Tags = parseTags(d.stringtable, rel.Keys, rel.Vals, r.Tags)
+
+		n := len(rel.Memids)
+		members := r.Members[:0]
+		if members == nil || cap(members) < n {
+			members, slab = slab[:n:n], slab[n:]
+		}
+		r.Members = parseRelationMembers(rel, d.stringtable, members[:n])
+
+		if d.includeMD {
+			r.Metadata = d.parseInfo(&rel.Info, r.Metadata)
+		} else {
+			r.Metadata = nil
 		}
 	}
 	return result
 }
 
+// reuseNodes returns nodes with len n, reusing the recycled slice if it
+// is large enough.
+func reuseNodes(nodes []osm.Node, n int) []osm.Node {
+	if cap(nodes) < n {
+		return make([]osm.Node, n)
+	}
+	return nodes[:n]
+}
+
+// reuseWays returns ways with len n, reusing the recycled slice if it is
+// large enough.
+func reuseWays(ways []osm.Way, n int) []osm.Way {
+	if cap(ways) < n {
+		return make([]osm.Way, n)
+	}
+	return ways[:n]
+}
+
+// reuseRelations returns relations with len n, reusing the recycled slice
+// if it is large enough.
+func reuseRelations(relations []osm.Relation, n int) []osm.Relation {
+	if cap(relations) < n {
+		return make([]osm.Relation, n)
+	}
+	return relations[:n]
+}
+
 type stringTable []string
 
-func newStringTable(source *osmpbf.StringTable) stringTable {
-	result := make(stringTable, len(source.S))
+// newStringTable returns the strings of source, reusing the result slice
+// and interned strings.
+func newStringTable(source *osmpbf.StringTable, result stringTable, strings stringInterner) stringTable {
+	if cap(result) < len(source.S) {
+		result = make(stringTable, len(source.S))
+	}
+	result = result[:len(source.S)]
 	for i, bytes := range source.S {
-		result[i] = string(bytes)
+		result[i] = strings.intern(bytes)
 	}
 	return result
 }
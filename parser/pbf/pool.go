@@ -0,0 +1,70 @@
+package pbf
+
+import (
+	"compress/zlib"
+	"io"
+	"sync"
+)
+
+// bufPool contains byte slices for reading and uncompressing blobs.
+var bufPool sync.Pool
+
+// getBuf returns a byte slice with len size, either from the bufPool or
+// newly allocated.
+func getBuf(size int) []byte {
+	if v := bufPool.Get(); v != nil {
+		b := *v.(*[]byte)
+		if cap(b) >= size {
+			return b[:size]
+		}
+	}
+	return make([]byte, size)
+}
+
+// putBuf returns b to the bufPool. b must not be used after this call.
+func putBuf(b []byte) {
+	if b == nil {
+		return
+	}
+	bufPool.Put(&b)
+}
+
+var zlibPool sync.Pool
+
+// getZlibReader returns a zlib reader for r, reusing a reader from
+// zlibPool if possible. Return the reader with putZlibReader.
+func getZlibReader(r io.Reader) (io.ReadCloser, error) {
+	if v := zlibPool.Get(); v != nil {
+		zr := v.(io.ReadCloser)
+		if err := zr.(zlib.Resetter).Reset(r, nil); err != nil {
+			return nil, err
+		}
+		return zr, nil
+	}
+	return zlib.NewReader(r)
+}
+
+func putZlibReader(zr io.ReadCloser) {
+	zlibPool.Put(zr)
+}
+
+// maxInternedStrings limits the size of a stringInterner.
+const maxInternedStrings = 1 << 16
+
+// stringInterner returns a shared string for equal byte slices. Tag keys,
+// common tag values and user names are repeated in most blocks and are
+// only allocated once. A stringInterner is not safe for concurrent use.
+type stringInterner map[string]string
+
+func (si stringInterner) intern(b []byte) string {
+	// map lookup with string(b) does not allocate
+	if s, ok := si[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if len(si) >= maxInternedStrings {
+		clear(si)
+	}
+	si[s] = s
+	return s
+}
@@ -0,0 +1,301 @@
+package pbf
+
+import (
+	structs "encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/omniscale/go-osm/parser/pbf/internal/osmpbf"
+)
+
+// blockBuf decodes PrimitiveBlocks and reuses the slices and nested
+// messages of the previous block. Once the slices are large enough, only
+// the elements of the non-dense groups allocate (Info.Version). The
+// returned PrimitiveBlock is only valid till the next call of unmarshal. A
+// blockBuf is not safe for concurrent use.
+type blockBuf struct {
+	block  osmpbf.PrimitiveBlock
+	st     osmpbf.StringTable
+	groups []*groupBuf
+
+	granularity     int32
+	dateGranularity int32
+	latOffset       int64
+	lonOffset       int64
+}
+
+// groupBuf contains a PrimitiveGroup and the DenseNodes that are reused
+// for the group. Dense and Denseinfo of the group are nil if they are
+// missing in the block.
+type groupBuf struct {
+	group osmpbf.PrimitiveGroup
+	dense osmpbf.DenseNodes
+	info  osmpbf.DenseInfo
+}
+
+// unmarshal decodes the encoded PrimitiveBlock data. All values are copied,
+// data can be reused after unmarshal returns.
+func (bb *blockBuf) unmarshal(data []byte) (*osmpbf.PrimitiveBlock, error) {
+	block := &bb.block
+	*block = osmpbf.PrimitiveBlock{Primitivegroup: block.Primitivegroup[:0]}
+	hasStringtable := false
+	err := forEachField(data, func(num, typ int, v uint64, b []byte) error {
+		switch {
+		case num == 1 && typ == wireBytes:
+			hasStringtable = true
+			block.Stringtable = &bb.st
+			return unmarshalStringTable(b, &bb.st)
+		case num == 2 && typ == wireBytes:
+			n := len(block.Primitivegroup)
+			if n == len(bb.groups) {
+				bb.groups = append(bb.groups, &groupBuf{})
+			}
+			g := bb.groups[n]
+			block.Primitivegroup = append(block.Primitivegroup, &g.group)
+			return g.unmarshal(b)
+		case num == 17 && typ == wireVarint:
+			bb.granularity = int32(v)
+			block.Granularity = &bb.granularity
+		case num == 18 && typ == wireVarint:
+			bb.dateGranularity = int32(v)
+			block.DateGranularity = &bb.dateGranularity
+		case num == 19 && typ == wireVarint:
+			bb.latOffset = int64(v)
+			block.LatOffset = &bb.latOffset
+		case num == 20 && typ == wireVarint:
+			bb.lonOffset = int64(v)
+			block.LonOffset = &bb.lonOffset
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !hasStringtable {
+		return nil, fmt.Errorf("PrimitiveBlock without stringtable")
+	}
+	return block, nil
+}
+
+// unmarshalStringTable decodes all strings into st, reusing the byte
+// slices of the previous strings.
+func unmarshalStringTable(data []byte, st *osmpbf.StringTable) error {
+	st.S = st.S[:0]
+	return forEachField(data, func(num, typ int, _ uint64, b []byte) error {
+		if num != 1 || typ != wireBytes {
+			return nil
+		}
+		n := len(st.S)
+		if n < cap(st.S) {
+			st.S = st.S[:n+1]
+			st.S[n] = append(st.S[n][:0], b...)
+		} else {
+			st.S = append(st.S, append([]byte(nil), b...))
+		}
+		return nil
+	})
+}
+
+func (g *groupBuf) unmarshal(data []byte) error {
+	grp := &g.group
+	*grp = osmpbf.PrimitiveGroup{
+		Nodes:     grp.Nodes[:0],
+		Ways:      grp.Ways[:0],
+		Relations: grp.Relations[:0],
+	}
+	return forEachField(data, func(num, typ int, _ uint64, b []byte) error {
+		if typ != wireBytes {
+			return nil
+		}
+		switch num {
+		case 1:
+			grp.Nodes = grow(grp.Nodes)
+			return unmarshalNode(b, &grp.Nodes[len(grp.Nodes)-1])
+		case 2:
+			grp.Dense = &g.dense
+			return g.unmarshalDense(b)
+		case 3:
+			grp.Ways = grow(grp.Ways)
+			return unmarshalWay(b, &grp.Ways[len(grp.Ways)-1])
+		case 4:
+			grp.Relations = grow(grp.Relations)
+			return unmarshalRelation(b, &grp.Relations[len(grp.Relations)-1])
+		case 5:
+			grp.Changesets = append(grp.Changesets, osmpbf.ChangeSet{})
+			return grp.Changesets[len(grp.Changesets)-1].Unmarshal(b)
+		}
+		return nil
+	})
+}
+
+// unmarshalDense decodes the DenseNodes. The packed fields are decoded
+// into the slices of the previous DenseNodes.
+func (g *groupBuf) unmarshalDense(data []byte) error {
+	d := &g.dense
+	*d = osmpbf.DenseNodes{Id: d.Id[:0], Lat: d.Lat[:0], Lon: d.Lon[:0], KeysVals: d.KeysVals[:0]}
+	return forEachField(data, func(num, typ int, v uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			d.Id, err = appendPacked(d.Id, typ, v, b, zigzag)
+		case 5:
+			if typ != wireBytes {
+				return errInvalidWireFormat
+			}
+			d.Denseinfo = &g.info
+			err = unmarshalDenseInfo(b, &g.info)
+		case 8:
+			d.Lat, err = appendPacked(d.Lat, typ, v, b, zigzag)
+		case 9:
+			d.Lon, err = appendPacked(d.Lon, typ, v, b, zigzag)
+		case 10:
+			d.KeysVals, err = appendPacked(d.KeysVals, typ, v, b, toInt32)
+		}
+		return err
+	})
+}
+
+func unmarshalDenseInfo(data []byte, info *osmpbf.DenseInfo) error {
+	*info = osmpbf.DenseInfo{
+		Version:   info.Version[:0],
+		Timestamp: info.Timestamp[:0],
+		Changeset: info.Changeset[:0],
+		Uid:       info.Uid[:0],
+		UserSid:   info.UserSid[:0],
+		Visible:   info.Visible[:0],
+	}
+	return forEachField(data, func(num, typ int, v uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			info.Version, err = appendPacked(info.Version, typ, v, b, toInt32)
+		case 2:
+			info.Timestamp, err = appendPacked(info.Timestamp, typ, v, b, zigzag)
+		case 3:
+			info.Changeset, err = appendPacked(info.Changeset, typ, v, b, zigzag)
+		case 4:
+			info.Uid, err = appendPacked(info.Uid, typ, v, b, zigzag32)
+		case 5:
+			info.UserSid, err = appendPacked(info.UserSid, typ, v, b, zigzag32)
+		case 6:
+			info.Visible, err = appendPacked(info.Visible, typ, v, b, func(v uint64) bool { return v != 0 })
+		}
+		return err
+	})
+}
+
+// The elements of non-dense groups reuse the slices of the element at the
+// same position in the previous block.
+
+func unmarshalNode(data []byte, nd *osmpbf.Node) error {
+	*nd = osmpbf.Node{Keys: nd.Keys[:0], Vals: nd.Vals[:0]}
+	return forEachField(data, func(num, typ int, v uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			nd.Id = zigzag(v)
+		case 2:
+			nd.Keys, err = appendPacked(nd.Keys, typ, v, b, toUint32)
+		case 3:
+			nd.Vals, err = appendPacked(nd.Vals, typ, v, b, toUint32)
+		case 4:
+			err = nd.Info.Unmarshal(b)
+		case 8:
+			nd.Lat = zigzag(v)
+		case 9:
+			nd.Lon = zigzag(v)
+		}
+		return err
+	})
+}
+
+func unmarshalWay(data []byte, w *osmpbf.Way) error {
+	*w = osmpbf.Way{Keys: w.Keys[:0], Vals: w.Vals[:0], Refs: w.Refs[:0], Lat: w.Lat[:0], Lon: w.Lon[:0]}
+	return forEachField(data, func(num, typ int, v uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			w.Id = int64(v)
+		case 2:
+			w.Keys, err = appendPacked(w.Keys, typ, v, b, toUint32)
+		case 3:
+			w.Vals, err = appendPacked(w.Vals, typ, v, b, toUint32)
+		case 4:
+			err = w.Info.Unmarshal(b)
+		case 8:
+			w.Refs, err = appendPacked(w.Refs, typ, v, b, zigzag)
+		case 9:
+			w.Lat, err = appendPacked(w.Lat, typ, v, b, zigzag)
+		case 10:
+			w.Lon, err = appendPacked(w.Lon, typ, v, b, zigzag)
+		}
+		return err
+	})
+}
+
+func unmarshalRelation(data []byte, r *osmpbf.Relation) error {
+	*r = osmpbf.Relation{Keys: r.Keys[:0], Vals: r.Vals[:0], RolesSid: r.RolesSid[:0], Memids: r.Memids[:0], Types: r.Types[:0]}
+	return forEachField(data, func(num, typ int, v uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			r.Id = int64(v)
+		case 2:
+			r.Keys, err = appendPacked(r.Keys, typ, v, b, toUint32)
+		case 3:
+			r.Vals, err = appendPacked(r.Vals, typ, v, b, toUint32)
+		case 4:
+			err = r.Info.Unmarshal(b)
+		case 8:
+			r.RolesSid, err = appendPacked(r.RolesSid, typ, v, b, toInt32)
+		case 9:
+			r.Memids, err = appendPacked(r.Memids, typ, v, b, zigzag)
+		case 10:
+			r.Types, err = appendPacked(r.Types, typ, v, b, func(v uint64) osmpbf.Relation_MemberType { return osmpbf.Relation_MemberType(v) })
+		}
+		return err
+	})
+}
+
+func toInt32(v uint64) int32   { return int32(v) }
+func toUint32(v uint64) uint32 { return uint32(v) }
+func zigzag32(v uint64) int32  { return int32(uint32(v)>>1) ^ -int32(v&1) }
+
+// appendPacked appends the values of a packed (wireBytes) or a single
+// (wireVarint) repeated field to dst. dst is grown once for all values of
+// packed fields.
+func appendPacked[T any](dst []T, typ int, v uint64, b []byte, conv func(uint64) T) ([]T, error) {
+	switch typ {
+	case wireVarint:
+		return append(dst, conv(v)), nil
+	case wireBytes:
+		// each value ends with a byte < 0x80
+		n := 0
+		for _, c := range b {
+			if c < 0x80 {
+				n++
+			}
+		}
+		dst = slices.Grow(dst, n)
+		for len(b) > 0 {
+			v, l := structs.Uvarint(b)
+			if l <= 0 {
+				return dst, errInvalidWireFormat
+			}
+			b = b[l:]
+			dst = append(dst, conv(v))
+		}
+		return dst, nil
+	}
+	return dst, errInvalidWireFormat
+}
+
+// grow returns s with one more element, reusing the element from a
+// previous block if s has enough capacity.
+func grow[T any](s []T) []T {
+	if len(s) < cap(s) {
+		return s[:len(s)+1]
+	}
+	var zero T
+	return append(s, zero)
+}
@@ -0,0 +1,470 @@
+package pbf
+
+import (
+	"bytes"
+	"compress/zlib"
+	structs "encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+	"github.com/omniscale/go-osm/parser/pbf/internal/osmpbf"
+)
+
+const (
+	defaultBlockSize = 8000
+	// coordinates are stored with the default granularity of 100 nanodegrees
+	coordPrecision = 1e7
+)
+
+type WriterConfig struct {
+	// Header specifies the replication information, bbox, source and
+	// writing program of the written file. WritingProgram defaults to
+	// github.com/omniscale/go-osm. Required features are set by the
+	// Writer.
+	//
+	// The locations of Way.Nodes are written with each way if
+	// Header.LocationsOnWays is true. Ways without Nodes or where the
+	// Nodes do not match the Refs are written without locations.
+	//
+	// The visible flag of each element is written if
+	// Header.HistoricalInformation is true. This requires IncludeMetadata.
+	Header Header
+
+	// IncludeMetadata indicates whether metadata like timestamps, versions
+	// and user names should be written.
+	IncludeMetadata bool
+
+	// BlockSize specifies the maximum number of elements in each
+	// PrimitiveBlock. Defaults to 8000 if <= 0.
+	BlockSize int
+}
+
+type elemType int
+
+const (
+	noElem elemType = iota
+	nodeElem
+	wayElem
+	relationElem
+)
+
+// Writer encodes nodes, ways and relations into a PBF file.
+//
+// Elements are collected into blocks of the same type. Nodes are stored as
+// DenseNodes. The writer does not sort the elements, you should write all
+// nodes before all ways before all relations to create files that other
+// tools (and the OnFirstWay/OnFirstRelation options of the Parser) expect.
+type Writer struct {
+	w             io.Writer
+	conf          WriterConfig
+	headerWritten bool
+	block         *blockBuilder
+	err           error
+}
+
+// NewWriter creates a new PBF writer for the provided output. Close needs to
+// be called after all elements are written.
+func NewWriter(w io.Writer, conf WriterConfig) *Writer {
+	if conf.BlockSize <= 0 {
+		conf.BlockSize = defaultBlockSize
+	}
+	return &Writer{
+		w:     w,
+		conf:  conf,
+		block: newBlockBuilder(conf),
+	}
+}
+
+// WriteNodes writes all nodes. The nodes are encoded immediately and can be
+// reused after WriteNodes returns.
+func (w *Writer) WriteNodes(nodes []osm.Node) error {
+	for i := range nodes {
+		if err := w.prepare(nodeElem); err != nil {
+			return err
+		}
+		w.block.addNode(&nodes[i])
+	}
+	return nil
+}
+
+// WriteWays writes all ways. The ways are encoded immediately and can be
+// reused after WriteWays returns.
+func (w *Writer) WriteWays(ways []osm.Way) error {
+	for i := range ways {
+		if err := w.prepare(wayElem); err != nil {
+			return err
+		}
+		w.block.addWay(&ways[i])
+	}
+	return nil
+}
+
+// WriteRelations writes all relations. The relations are encoded immediately
+// and can be reused after WriteRelations returns.
+func (w *Writer) WriteRelations(rels []osm.Relation) error {
+	for i := range rels {
+		if err := w.prepare(relationElem); err != nil {
+			return err
+		}
+		w.block.addRelation(&rels[i])
+	}
+	return nil
+}
+
+// Close writes all pending elements. It does not close the underlying
+// io.Writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.err = stream.ErrWriterClosed
+	return nil
+}
+
+// prepare makes sure that the next element of type t can be added to the
+// current block.
+func (w *Writer) prepare(t elemType) error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	if w.block.typ != noElem && (w.block.typ != t || w.block.count >= w.conf.BlockSize) {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	w.block.typ = t
+	return nil
+}
+
+func (w *Writer) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+
+	h := w.conf.Header
+	header := &osmpbf.HeaderBlock{
+		RequiredFeatures:                 []string{"OsmSchema-V0.6", "DenseNodes"},
+		Writingprogram:                   h.WritingProgram,
+		Source:                           h.Source,
+		OsmosisReplicationSequenceNumber: h.Sequence,
+		OsmosisReplicationBaseUrl:        h.ReplicationBaseURL,
+	}
+	for _, f := range h.OptionalFeatures {
+		if f != locationsOnWays {
+			header.OptionalFeatures = append(header.OptionalFeatures, f)
+		}
+	}
+	if h.HistoricalInformation {
+		header.RequiredFeatures = append(header.RequiredFeatures, historicalInformation)
+	}
+	if h.LocationsOnWays {
+		header.OptionalFeatures = append(header.OptionalFeatures, locationsOnWays)
+	}
+	if header.Writingprogram == "" {
+		header.Writingprogram = stream.DefaultGenerator
+	}
+	if !h.Time.IsZero() {
+		header.OsmosisReplicationTimestamp = h.Time.Unix()
+	}
+	if h.BBox != [4]float64{} {
+		header.Bbox = &osmpbf.HeaderBBox{
+			Left:   int64(math.Round(h.BBox[0] / bboxScale)),
+			Bottom: int64(math.Round(h.BBox[1] / bboxScale)),
+			Right:  int64(math.Round(h.BBox[2] / bboxScale)),
+			Top:    int64(math.Round(h.BBox[3] / bboxScale)),
+		}
+	}
+
+	data, err := proto.Marshal(header)
+	if err != nil {
+		w.err = fmt.Errorf("marshaling HeaderBlock: %w", err)
+		return w.err
+	}
+	if err := w.writeBlob("OSMHeader", data); err != nil {
+		w.err = fmt.Errorf("writing header: %w", err)
+		return w.err
+	}
+	return nil
+}
+
+func (w *Writer) flush() error {
+	if w.block.count == 0 {
+		return nil
+	}
+	data, err := proto.Marshal(w.block.primitiveBlock())
+	if err != nil {
+		w.err = fmt.Errorf("marshaling PrimitiveBlock: %w", err)
+		return w.err
+	}
+	if err := w.writeBlob("OSMData", data); err != nil {
+		w.err = fmt.Errorf("writing block: %w", err)
+		return w.err
+	}
+	w.block = newBlockBuilder(w.conf)
+	return nil
+}
+
+// writeBlob writes the BlobHeader and the zlib compressed Blob for the
+// encoded HeaderBlock or PrimitiveBlock.
+func (w *Writer) writeBlob(typ string, data []byte) error {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("compressing blob: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compressing blob: %w", err)
+	}
+
+	blob, err := proto.Marshal(&osmpbf.Blob{
+		RawSize:  int32(len(data)),
+		ZlibData: buf.Bytes(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling blob: %w", err)
+	}
+
+	header, err := proto.Marshal(&osmpbf.BlobHeader{
+		Type:     typ,
+		Datasize: int32(len(blob)),
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling blob header: %w", err)
+	}
+
+	if err := structs.Write(w.w, structs.BigEndian, int32(len(header))); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(blob); err != nil {
+		return err
+	}
+	return nil
+}
+
+// blockBuilder collects elements of a single type for the next
+// PrimitiveBlock.
+type blockBuilder struct {
+	typ             elemType
+	count           int
+	includeMD       bool
+	locationsOnWays bool
+	historical      bool
+
+	strings     map[string]int32
+	stringtable [][]byte
+
+	dense     *osmpbf.DenseNodes
+	ways      []osmpbf.Way
+	relations []osmpbf.Relation
+
+	lastID        int64
+	lastLat       int64
+	lastLon       int64
+	lastTimestamp int64
+	lastChangeset int64
+	lastUID       int32
+	lastUserSID   int32
+}
+
+func newBlockBuilder(conf WriterConfig) *blockBuilder {
+	return &blockBuilder{
+		includeMD:       conf.IncludeMetadata,
+		locationsOnWays: conf.Header.LocationsOnWays,
+		historical:      conf.Header.HistoricalInformation,
+		// first string is reserved as delimiter for DenseNodes.KeysVals
+		strings:     map[string]int32{"": 0},
+		stringtable: [][]byte{{}},
+	}
+}
+
+func (b *blockBuilder) stringID(s string) int32 {
+	if id, ok := b.strings[s]; ok {
+		return id
+	}
+	id := int32(len(b.stringtable))
+	b.strings[s] = id
+	b.stringtable = append(b.stringtable, []byte(s))
+	return id
+}
+
+func (b *blockBuilder) addNode(nd *osm.Node) {
+	if b.dense == nil {
+		b.dense = &osmpbf.DenseNodes{}
+		if b.includeMD {
+			b.dense.Denseinfo = &osmpbf.DenseInfo{}
+		}
+	}
+	b.count++
+
+	lat := coordToInt(nd.Lat)
+	lon := coordToInt(nd.Long)
+	b.dense.Id = append(b.dense.Id, nd.ID-b.lastID)
+	b.dense.Lat = append(b.dense.Lat, lat-b.lastLat)
+	b.dense.Lon = append(b.dense.Lon, lon-b.lastLon)
+	b.lastID, b.lastLat, b.lastLon = nd.ID, lat, lon
+
+	for _, k := range sortedKeys(nd.Tags) {
+		b.dense.KeysVals = append(b.dense.KeysVals, b.stringID(k), b.stringID(nd.Tags[k]))
+	}
+	b.dense.KeysVals = append(b.dense.KeysVals, 0)
+
+	if b.includeMD {
+		md := nd.Metadata
+		if md == nil {
+			md = &osm.Metadata{Visible: true}
+		}
+		info := b.dense.Denseinfo
+		timestamp := md.Timestamp.Unix()
+		if md.Timestamp.IsZero() {
+			timestamp = 0
+		}
+		userSID := b.stringID(md.UserName)
+		info.Version = append(info.Version, md.Version)
+		info.Timestamp = append(info.Timestamp, timestamp-b.lastTimestamp)
+		info.Changeset = append(info.Changeset, md.Changeset-b.lastChangeset)
+		info.Uid = append(info.Uid, md.UserID-b.lastUID)
+		info.UserSid = append(info.UserSid, userSID-b.lastUserSID)
+		if b.historical {
+			info.Visible = append(info.Visible, md.Visible)
+		}
+		b.lastTimestamp, b.lastChangeset = timestamp, md.Changeset
+		b.lastUID, b.lastUserSID = md.UserID, userSID
+	}
+}
+
+func (b *blockBuilder) addWay(w *osm.Way) {
+	b.count++
+	way := osmpbf.Way{Id: w.ID}
+	way.Keys, way.Vals = b.tags(w.Tags)
+	way.Refs = deltaRefs(w.Refs)
+	if b.locationsOnWays && len(w.Nodes) == len(w.Refs) && len(w.Refs) > 0 {
+		way.Lat = make([]int64, len(w.Nodes))
+		way.Lon = make([]int64, len(w.Nodes))
+		var lastLat, lastLon int64
+		for i := range w.Nodes {
+			lat := coordToInt(w.Nodes[i].Lat)
+			lon := coordToInt(w.Nodes[i].Long)
+			way.Lat[i] = lat - lastLat
+			way.Lon[i] = lon - lastLon
+			lastLat, lastLon = lat, lon
+		}
+	}
+	if b.includeMD {
+		way.Info = b.info(w.Metadata)
+	}
+	b.ways = append(b.ways, way)
+}
+
+func (b *blockBuilder) addRelation(r *osm.Relation) {
+	b.count++
+	rel := osmpbf.Relation{Id: r.ID}
+	rel.Keys, rel.Vals = b.tags(r.Tags)
+	rel.RolesSid = make([]int32, len(r.Members))
+	rel.Memids = make([]int64, len(r.Members))
+	rel.Types = make([]osmpbf.Relation_MemberType, len(r.Members))
+	var lastID int64
+	for i, m := range r.Members {
+		rel.RolesSid[i] = b.stringID(m.Role)
+		rel.Memids[i] = m.ID - lastID
+		rel.Types[i] = osmpbf.Relation_MemberType(m.Type)
+		lastID = m.ID
+	}
+	if b.includeMD {
+		rel.Info = b.info(r.Metadata)
+	}
+	b.relations = append(b.relations, rel)
+}
+
+func (b *blockBuilder) tags(tags osm.Tags) (keys, vals []uint32) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	keys = make([]uint32, 0, len(tags))
+	vals = make([]uint32, 0, len(tags))
+	for _, k := range sortedKeys(tags) {
+		keys = append(keys, uint32(b.stringID(k)))
+		vals = append(vals, uint32(b.stringID(tags[k])))
+	}
+	return keys, vals
+}
+
+func (b *blockBuilder) info(md *osm.Metadata) osmpbf.Info {
+	if md == nil {
+		return osmpbf.Info{Visible: b.historical}
+	}
+	version := md.Version
+	info := osmpbf.Info{
+		Version:   &version,
+		Changeset: md.Changeset,
+		Uid:       md.UserID,
+		UserSid:   uint32(b.stringID(md.UserName)),
+		Visible:   b.historical && md.Visible,
+	}
+	if !md.Timestamp.IsZero() {
+		info.Timestamp = md.Timestamp.Unix()
+	}
+	return info
+}
+
+func (b *blockBuilder) primitiveBlock() *osmpbf.PrimitiveBlock {
+	group := &osmpbf.PrimitiveGroup{}
+	switch b.typ {
+	case nodeElem:
+		group.Dense = b.dense
+	case wayElem:
+		group.Ways = b.ways
+	case relationElem:
+		group.Relations = b.relations
+	}
+	return &osmpbf.PrimitiveBlock{
+		Stringtable:    &osmpbf.StringTable{S: b.stringtable},
+		Primitivegroup: []*osmpbf.PrimitiveGroup{group},
+	}
+}
+
+func coordToInt(c float64) int64 {
+	return int64(math.Round(c * coordPrecision))
+}
+
+func deltaRefs(refs []int64) []int64 {
+	if len(refs) == 0 {
+		return nil
+	}
+	result := make([]int64, len(refs))
+	var lastRef int64
+	for i, ref := range refs {
+		result[i] = ref - lastRef
+		lastRef = ref
+	}
+	return result
+}
+
+func sortedKeys(tags osm.Tags) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,336 @@
+package pbf
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/internal/stream"
+)
+
+type parsedElements struct {
+	header    *Header
+	nodes     []osm.Node
+	ways      []osm.Way
+	relations []osm.Relation
+}
+
+func parseAll(t *testing.T, r io.Reader, includeMD bool) parsedElements {
+	t.Helper()
+	conf := Config{
+		IncludeMetadata: includeMD,
+		Nodes:           make(chan []osm.Node),
+		Ways:            make(chan []osm.Way),
+		Relations:       make(chan []osm.Relation),
+		Concurrency:     1,
+	}
+	p := New(r, conf)
+
+	result := parsedElements{}
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go func() {
+		for nds := range conf.Nodes {
+			result.nodes = append(result.nodes, nds...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for ws := range conf.Ways {
+			result.ways = append(result.ways, ws...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for rs := range conf.Relations {
+			result.relations = append(result.relations, rs...)
+		}
+		wg.Done()
+	}()
+
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	header, err := p.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.header = header
+	return result
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for _, includeMD := range []bool{false, true} {
+		f, err := os.Open("./monaco-20150428.osm.pbf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := parseAll(t, f, includeMD)
+		f.Close()
+
+		buf := &bytes.Buffer{}
+		w := NewWriter(buf, WriterConfig{
+			IncludeMetadata: includeMD,
+			Header:          Header{Time: time.Unix(1430172000, 0), Sequence: 1234},
+		})
+		if err := w.WriteNodes(want.nodes); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteWays(want.ways); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteRelations(want.relations); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		got := parseAll(t, buf, includeMD)
+
+		if !got.header.Time.Equal(time.Unix(1430172000, 0)) || got.header.Sequence != 1234 {
+			t.Errorf("unexpected header %#v", got.header)
+		}
+		if !reflect.DeepEqual(got.nodes, want.nodes) {
+			t.Errorf("nodes differ after round trip (metadata %v)", includeMD)
+		}
+		if !reflect.DeepEqual(got.ways, want.ways) {
+			t.Errorf("ways differ after round trip (metadata %v)", includeMD)
+		}
+		if !reflect.DeepEqual(got.relations, want.relations) {
+			t.Errorf("relations differ after round trip (metadata %v)", includeMD)
+		}
+	}
+}
+
+func TestWriterBlockSize(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{BlockSize: 2})
+	nodes := []osm.Node{
+		{Element: osm.Element{ID: 1}, Lat: 53.1, Long: 8.2},
+		{Element: osm.Element{ID: 2, Tags: osm.Tags{"name": "<foo>"}}, Lat: -53.1, Long: -8.2},
+		{Element: osm.Element{ID: 5}, Lat: 0, Long: 0},
+	}
+	ways := []osm.Way{
+		{Element: osm.Element{ID: 3, Tags: osm.Tags{"highway": "track"}}, Refs: []int64{1, 2, 5}},
+	}
+	if err := w.WriteNodes(nodes); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteWays(ways); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteWays(ways); err != stream.ErrWriterClosed {
+		t.Error("expected error after Close, got", err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	if _, err := parseHeader(r); err != nil {
+		t.Fatal(err)
+	}
+	numBlocks := 0
+	for {
+		_, _, err := nextBlock(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		numBlocks++
+	}
+	// two node blocks and one way block
+	if numBlocks != 3 {
+		t.Error("unexpected number of blocks", numBlocks)
+	}
+
+	got := parseAll(t, bytes.NewReader(buf.Bytes()), false)
+	if len(got.nodes) != len(nodes) {
+		t.Fatalf("unexpected nodes %#v", got.nodes)
+	}
+	for i, nd := range got.nodes {
+		if nd.ID != nodes[i].ID || !reflect.DeepEqual(nd.Tags, nodes[i].Tags) ||
+			math.Abs(nd.Lat-nodes[i].Lat) > 1e-7 || math.Abs(nd.Long-nodes[i].Long) > 1e-7 {
+			t.Errorf("unexpected node %#v, want %#v", nd, nodes[i])
+		}
+	}
+	if !reflect.DeepEqual(got.ways, ways) {
+		t.Errorf("unexpected ways %#v", got.ways)
+	}
+}
+
+func TestWriterHeader(t *testing.T) {
+	want := Header{
+		Time:               time.Unix(1430166062, 0),
+		Sequence:           42,
+		ReplicationBaseURL: "https://planet.openstreetmap.org/replication/minute/",
+		BBox:               [4]float64{-180, -85.0511, 180, 85.0511},
+		WritingProgram:     "test",
+		Source:             "https://www.openstreetmap.org/api/0.6",
+		RequiredFeatures:   []string{"OsmSchema-V0.6", "DenseNodes"},
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{Header: want})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := New(buf, Config{}).Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("unexpected header\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestWriterLocationsOnWays(t *testing.T) {
+	f, err := os.Open("./monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	monaco := parseAll(t, f, false)
+	f.Close()
+
+	coords := make(map[int64]osm.Node, len(monaco.nodes))
+	for _, nd := range monaco.nodes {
+		coords[nd.ID] = osm.Node{Element: osm.Element{ID: nd.ID}, Lat: nd.Lat, Long: nd.Long}
+	}
+	for i := range monaco.ways {
+		w := &monaco.ways[i]
+		w.Nodes = make([]osm.Node, len(w.Refs))
+		for j, ref := range w.Refs {
+			w.Nodes[j] = coords[ref]
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{Header: Header{LocationsOnWays: true}})
+	if err := w.WriteWays(monaco.ways); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := parseAll(t, buf, false)
+	if !got.header.LocationsOnWays || !reflect.DeepEqual(got.header.OptionalFeatures, []string{"LocationsOnWays"}) {
+		t.Errorf("LocationsOnWays not set in header %#v", got.header)
+	}
+	if !reflect.DeepEqual(got.ways, monaco.ways) {
+		t.Error("ways with locations differ after round trip")
+	}
+	if len(got.ways[0].Nodes) == 0 {
+		t.Error("way without nodes")
+	}
+}
+
+func TestWriterHistoricalInformation(t *testing.T) {
+	md := func(version int32, visible bool) *osm.Metadata {
+		return &osm.Metadata{
+			UserID:    42,
+			UserName:  "foo",
+			Version:   version,
+			Timestamp: time.Unix(1430166062+int64(version), 0),
+			Changeset: 1000 + int64(version),
+			Visible:   visible,
+		}
+	}
+	nodes := []osm.Node{
+		{Element: osm.Element{ID: 1, Metadata: md(1, true)}, Lat: 53, Long: 8},
+		{Element: osm.Element{ID: 1, Metadata: md(2, true), Tags: osm.Tags{"name": "foo"}}, Lat: 53.5, Long: 8.5},
+		{Element: osm.Element{ID: 1, Metadata: md(3, false)}},
+		{Element: osm.Element{ID: 2, Metadata: md(1, true)}, Lat: 54, Long: 9},
+	}
+	ways := []osm.Way{
+		{Element: osm.Element{ID: 1, Metadata: md(1, true), Tags: osm.Tags{"highway": "track"}}, Refs: []int64{1, 2}},
+		{Element: osm.Element{ID: 1, Metadata: md(2, false)}, Refs: []int64{}},
+	}
+	rels := []osm.Relation{
+		{Element: osm.Element{ID: 1, Metadata: md(1, true)}, Members: []osm.Member{{ID: 1, Type: osm.WayMember, Role: "outer"}}},
+		{Element: osm.Element{ID: 1, Metadata: md(2, false)}, Members: []osm.Member{}},
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, WriterConfig{IncludeMetadata: true, Header: Header{HistoricalInformation: true}})
+	w.WriteNodes(nodes)
+	w.WriteWays(ways)
+	w.WriteRelations(rels)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{
+		IncludeMetadata: true,
+		Coords:          make(chan []osm.Node),
+		Nodes:           make(chan []osm.Node),
+		Ways:            make(chan []osm.Way),
+		Relations:       make(chan []osm.Relation),
+	}
+	var gotNodes []osm.Node
+	var gotWays []osm.Way
+	var gotRels []osm.Relation
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	go func() {
+		for range conf.Coords {
+		}
+		wg.Done()
+	}()
+	go func() {
+		for nds := range conf.Nodes {
+			gotNodes = append(gotNodes, nds...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for ws := range conf.Ways {
+			gotWays = append(gotWays, ws...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for rs := range conf.Relations {
+			gotRels = append(gotRels, rs...)
+		}
+		wg.Done()
+	}()
+	p := New(buf, conf)
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	header, err := p.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.HistoricalInformation {
+		t.Errorf("HistoricalInformation not set in header %#v", header)
+	}
+
+	// only nodes with tags and deleted nodes are sent to Nodes
+	if len(gotNodes) != 2 || !reflect.DeepEqual(gotNodes[0].Metadata, nodes[1].Metadata) ||
+		!reflect.DeepEqual(gotNodes[1], nodes[2]) {
+		t.Errorf("unexpected nodes %#v", gotNodes)
+	}
+	if !reflect.DeepEqual(gotWays, ways) {
+		t.Errorf("unexpected ways %#v", gotWays)
+	}
+	if !reflect.DeepEqual(gotRels, rels) {
+		t.Errorf("unexpected relations %#v", gotRels)
+	}
+}
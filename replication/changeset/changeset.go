@@ -1,12 +1,11 @@
 package changeset
 
 import (
-	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
+	"os"
 	"time"
 
 	"github.com/omniscale/go-osm/replication"
@@ -20,14 +19,29 @@ import (
 // possible with a single connection until the first changeset is missing.
 // After that, it uses the interval to estimate when a new changeset should
 // appear. The returned replication.Source provides metadata for each
-// downloaded changeset.
+// downloaded changeset. Downloaded files and existing files from previous runs
+// are verified, invalid files are removed and downloaded again.
 func NewDownloader(changesetDir, url string, seq int, interval time.Duration) replication.Source {
-	dl := source.NewDownloader(changesetDir, url, seq, interval)
+	dl, _ := NewDownloaderWithConfig(changesetDir, url, seq, interval, replication.DownloaderConfig{})
+	return dl
+}
+
+// NewDownloaderWithConfig starts a background downloader like
+// NewDownloader, with the additional options from conf. It returns an
+// error if the conf.StateFile exists but cannot be read. The returned
+// Source implements replication.Acker to update the conf.StateFile.
+func NewDownloaderWithConfig(changesetDir, url string, seq int, interval time.Duration, conf replication.DownloaderConfig) (replication.Source, error) {
+	dl := source.NewDownloader(changesetDir, url, seq, interval, conf)
 	dl.FileExt = ".osm.gz"
 	dl.StateExt = ".state.txt"
 	dl.StateTime = parseYamlTime
+	dl.ParseState = parseYamlStateSeq
+	dl.FileRoot = "osm"
+	if err := dl.Resume(); err != nil {
+		return nil, err
+	}
 	go dl.Start()
-	return dl
+	return dl, nil
 }
 
 // NewReader starts a goroutine to search for OSM changeset files (.osm.gz).
@@ -50,25 +64,47 @@ func NewReader(changesetDir string, seq int) replication.Source {
 // given replication URL (e.g.
 // https://planet.openstreetmap.org/replication/changesets/)
 func CurrentSequence(replURL string) (int, error) {
-	resp, err := http.Get(replURL + "state.yaml")
+	return CurrentSequenceWithConfig(replURL, replication.DownloaderConfig{})
+}
+
+// CurrentSequenceWithConfig returns the ID of the latest changeset like
+// CurrentSequence, with the HTTP options from conf.
+func CurrentSequenceWithConfig(replURL string, conf replication.DownloaderConfig) (int, error) {
+	b, err := source.NewClient(replURL, conf).Get(context.Background(), "state.yaml")
 	if err != nil {
 		return 0, err
 	}
-	if resp.StatusCode != 200 {
-		return 0, errors.New(fmt.Sprintf("invalid repsonse: %v", resp))
-	}
-	defer resp.Body.Close()
-	b := &bytes.Buffer{}
-	if _, err := io.Copy(b, resp.Body); err != nil {
-		return 0, err
-	}
-	state, err := parseYamlState(b.Bytes())
+	state, err := parseYamlState(b)
 	if err != nil {
 		return 0, err
 	}
 	return state.Sequence, nil
 }
 
+// SequenceForTime returns the ID of the last changeset replication file at
+// the given replication URL (e.g.
+// https://planet.openstreetmap.org/replication/changesets/) that is older
+// than t minus margin. margin adds an overlap to make sure that no
+// changesets are missed.
+func SequenceForTime(replURL string, t time.Time, margin time.Duration) (int, error) {
+	return SequenceForTimeWithConfig(replURL, t, margin, replication.DownloaderConfig{})
+}
+
+// SequenceForTimeWithConfig returns the ID like SequenceForTime, with the
+// HTTP options from conf.
+func SequenceForTimeWithConfig(replURL string, t time.Time, margin time.Duration, conf replication.DownloaderConfig) (int, error) {
+	client := source.NewClient(replURL, conf)
+	return source.SequenceForTime(client, "state.yaml", ".state.txt", parseYamlStateSeq, t.Add(-margin))
+}
+
+func parseYamlStateSeq(b []byte) (int, time.Time, error) {
+	state, err := parseYamlState(b)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return state.Sequence, state.Time.Time, nil
+}
+
 type changesetState struct {
 	Time     yamlStateTime `yaml:"last_run"`
 	Sequence int           `yaml:"sequence"`
@@ -78,6 +114,10 @@ type yamlStateTime struct {
 	time.Time
 }
 
+// yamlTimeFormat is used for writing last_run, the fraction is optional
+// for parsing.
+const yamlTimeFormat = "2006-01-02 15:04:05.000000000 -07:00"
+
 func (y *yamlStateTime) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var ts string
 	if err := unmarshal(&ts); err != nil {
@@ -88,6 +128,34 @@ func (y *yamlStateTime) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return err
 }
 
+// WriteStateFile writes the state for the changeset replication file seq
+// to filename, in the same YAML format as the state.yaml and
+// AAA/BBB/CCC.state.txt files of planet.openstreetmap.org. lastRun is the
+// time when the replication file was created. The file is replaced
+// atomically.
+func WriteStateFile(filename string, seq int, lastRun time.Time) error {
+	tmpname := filename + "~"
+	f, err := os.Create(tmpname)
+	if err != nil {
+		return fmt.Errorf("creating temp file for writing state file: %w", err)
+	}
+	err = writeYamlState(f, seq, lastRun)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpname)
+		return fmt.Errorf("writing state to %q: %w", tmpname, err)
+	}
+	return os.Rename(tmpname, filename)
+}
+
+func writeYamlState(w io.Writer, seq int, lastRun time.Time) error {
+	_, err := fmt.Fprintf(w, "---\nlast_run: %s\nsequence: %d\n",
+		lastRun.UTC().Format(yamlTimeFormat), seq)
+	return err
+}
+
 func parseYamlStateFile(filename string) (changesetState, error) {
 	b, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -1,9 +1,15 @@
 package changeset
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"time"
 
 	"testing"
+
+	"github.com/omniscale/go-osm/replication/internal/source"
 )
 
 func TestParseYamlState(t *testing.T) {
@@ -24,3 +30,57 @@ sequence: 2139110
 	}
 
 }
+
+func TestWriteStateFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.yaml")
+	lastRun := time.Date(2016, 12, 07, 19, 16, 01, 500000000, time.UTC)
+	if err := WriteStateFile(filename, 2139110, lastRun); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != `---
+last_run: 2016-12-07 19:16:01.500000000 +00:00
+sequence: 2139110
+` {
+		t.Error("unexpected content", string(content))
+	}
+
+	state, err := parseYamlStateFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Sequence != 2139110 || !state.Time.Time.Equal(lastRun) {
+		t.Error("unexpected state", state)
+	}
+}
+
+func TestSequenceForTime(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	for seq := 0; seq <= 300; seq++ {
+		filename := filepath.Join(dir, filepath.FromSlash(source.SeqPath(seq))+".state.txt")
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteStateFile(filename, seq, start.Add(time.Duration(seq)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteStateFile(filepath.Join(dir, "state.yaml"), 300, start.Add(300*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	seq, err := SequenceForTime(srv.URL+"/", start.Add(234*time.Minute+time.Second), 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 224 {
+		t.Error("unexpected sequence", seq)
+	}
+}
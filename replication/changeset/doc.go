@@ -1,2 +1,3 @@
-// Package changeset provides functions for downloading OSM changeset files.
+// Package changeset provides functions for downloading OSM changeset files
+// and for writing their state files.
 package changeset
@@ -1,9 +1,8 @@
 package diff
 
 import (
-	"errors"
-	"fmt"
-	"net/http"
+	"bytes"
+	"context"
 	"time"
 
 	"github.com/omniscale/go-osm/replication"
@@ -17,35 +16,77 @@ import (
 // possible with a single connection until the first diff is missing.
 // After that, it uses the interval to estimate when a new diff should
 // appear. The returned replication.Source provides metadata for each
-// downloaded diff.
+// downloaded diff. Downloaded files and existing files from previous runs
+// are verified, invalid files are removed and downloaded again.
 func NewDownloader(diffDir, url string, seq int, interval time.Duration) replication.Source {
-	dl := source.NewDownloader(diffDir, url, seq, interval)
+	dl, _ := NewDownloaderWithConfig(diffDir, url, seq, interval, replication.DownloaderConfig{})
+	return dl
+}
+
+// NewDownloaderWithConfig starts a background downloader like
+// NewDownloader, with the additional options from conf. It returns an
+// error if the conf.StateFile exists but cannot be read. The returned
+// Source implements replication.Acker to update the conf.StateFile.
+func NewDownloaderWithConfig(diffDir, url string, seq int, interval time.Duration, conf replication.DownloaderConfig) (replication.Source, error) {
+	dl := source.NewDownloader(diffDir, url, seq, interval, conf)
 	dl.FileExt = ".osc.gz"
 	dl.StateExt = ".state.txt"
 	dl.StateTime = parseTxtTime
+	dl.ParseState = parseTxtState
+	dl.FileRoot = "osmChange"
+	if err := dl.Resume(); err != nil {
+		return nil, err
+	}
 	go dl.Start()
-	return dl
+	return dl, nil
 }
 
 // CurrentSequence returns the ID of the latest diff available at the
 // given replication URL (e.g.
 // https://planet.openstreetmap.org/replication/minute/)
 func CurrentSequence(replURL string) (int, error) {
-	resp, err := http.Get(replURL + "state.txt")
+	return CurrentSequenceWithConfig(replURL, replication.DownloaderConfig{})
+}
+
+// CurrentSequenceWithConfig returns the ID of the latest diff like
+// CurrentSequence, with the HTTP options from conf.
+func CurrentSequenceWithConfig(replURL string, conf replication.DownloaderConfig) (int, error) {
+	b, err := source.NewClient(replURL, conf).Get(context.Background(), "state.txt")
 	if err != nil {
 		return 0, err
 	}
-	if resp.StatusCode != 200 {
-		return 0, errors.New(fmt.Sprintf("invalid repsonse: %v", resp))
-	}
-	defer resp.Body.Close()
-	s, err := state.Parse(resp.Body)
+	s, err := state.Parse(bytes.NewReader(b))
 	if err != nil {
 		return 0, err
 	}
 	return s.Sequence, nil
 }
 
+// SequenceForTime returns the ID of the last diff at the given replication
+// URL (e.g. https://planet.openstreetmap.org/replication/minute/) that is
+// older than t minus margin. This can be used to start a downloader for a
+// PBF file with a known timestamp (pbf.Header.Time). margin adds an overlap
+// for changes that were not yet included in the PBF file. Diffs can be
+// applied multiple times, so a few minutes of margin are harmless.
+func SequenceForTime(replURL string, t time.Time, margin time.Duration) (int, error) {
+	return SequenceForTimeWithConfig(replURL, t, margin, replication.DownloaderConfig{})
+}
+
+// SequenceForTimeWithConfig returns the ID like SequenceForTime, with the
+// HTTP options from conf.
+func SequenceForTimeWithConfig(replURL string, t time.Time, margin time.Duration, conf replication.DownloaderConfig) (int, error) {
+	client := source.NewClient(replURL, conf)
+	return source.SequenceForTime(client, "state.txt", ".state.txt", parseTxtState, t.Add(-margin))
+}
+
+func parseTxtState(b []byte) (int, time.Time, error) {
+	s, err := state.Parse(bytes.NewReader(b))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return s.Sequence, s.Time, nil
+}
+
 func parseTxtTime(filename string) (time.Time, error) {
 	ds, err := state.ParseFile(filename)
 	if err != nil {
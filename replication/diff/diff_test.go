@@ -0,0 +1,196 @@
+package diff
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/replication"
+	"github.com/omniscale/go-osm/state"
+)
+
+// publishTestDiffs publishes n sequences with a single node into a new
+// replication directory and returns a server for this directory.
+func publishTestDiffs(t *testing.T, n int, start time.Time) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.FileServer(http.Dir(publishTestDiffDir(t, n, start))))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// publishTestDiffDir publishes n sequences with a single node into a new
+// replication directory and returns the directory.
+func publishTestDiffDir(t *testing.T, n int, start time.Time) string {
+	t.Helper()
+	dir := t.TempDir()
+	p, err := NewPublisher(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		diffs := []osm.Diff{{Create: true, Node: &osm.Node{Element: osm.Element{ID: int64(i)}}}}
+		if _, err := p.Publish(diffs, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDownloaderStateFile(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	srv := publishTestDiffs(t, 3, start)
+
+	diffDir := t.TempDir()
+	stateFile := filepath.Join(t.TempDir(), "last.state.txt")
+	conf := replication.DownloaderConfig{StateFile: stateFile}
+
+	dl, err := NewDownloaderWithConfig(diffDir, srv.URL+"/", 1, time.Minute, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq := <-dl.Sequences(); seq.Sequence != 1 {
+		t.Fatalf("unexpected sequence %#v", seq)
+	}
+	if err := dl.(replication.Acker).Ack(1); err != nil {
+		t.Fatal(err)
+	}
+	// sequence 2 is received but not acknowledged
+	if seq := <-dl.Sequences(); seq.Sequence != 2 {
+		t.Fatalf("unexpected sequence %#v", seq)
+	}
+	dl.Stop()
+
+	s, err := state.ParseFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Sequence != 1 || !s.Time.Equal(start.Add(time.Minute)) || s.URL != srv.URL+"/" {
+		t.Errorf("unexpected state %#v", s)
+	}
+
+	// resumes after the acknowledged sequence, seq is ignored
+	dl, err = NewDownloaderWithConfig(diffDir, srv.URL+"/", 1, time.Minute, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Stop()
+	if seq := <-dl.Sequences(); seq.Sequence != 2 {
+		t.Fatalf("unexpected sequence after resume %#v", seq)
+	}
+}
+
+func TestDownloaderInvalidStateFile(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "last.state.txt")
+	if err := os.WriteFile(stateFile, []byte("invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := replication.DownloaderConfig{StateFile: stateFile}
+	if _, err := NewDownloaderWithConfig(t.TempDir(), "http://localhost/", 1, time.Minute, conf); err == nil {
+		t.Error("expected error for invalid state file")
+	}
+}
+
+func TestSequenceForTime(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	srv := publishTestDiffs(t, 20, start)
+
+	// sequence 10 is from start + 10 minutes
+	seq, err := SequenceForTime(srv.URL+"/", start.Add(10*time.Minute+30*time.Second), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 10 {
+		t.Error("unexpected sequence", seq)
+	}
+
+	seq, err = SequenceForTime(srv.URL+"/", start.Add(10*time.Minute+30*time.Second), 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 5 {
+		t.Error("unexpected sequence with margin", seq)
+	}
+
+	// publisher starts with sequence 1
+	if seq, err := SequenceForTime(srv.URL+"/", start, 0); err == nil {
+		t.Error("expected error for time before first sequence, got", seq)
+	}
+}
+
+func TestDownloaderConcurrency(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	files := http.FileServer(http.Dir(publishTestDiffDir(t, 20, start)))
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	requests := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		files.ServeHTTP(w, r)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	dl, err := NewDownloaderWithConfig(t.TempDir(), srv.URL+"/", 1, time.Minute, replication.DownloaderConfig{Concurrency: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Stop()
+
+	for i := 1; i <= 20; i++ {
+		seq := <-dl.Sequences()
+		if seq.Error != nil {
+			t.Fatal(seq.Error)
+		}
+		if seq.Sequence != i || !seq.Time.Equal(start.Add(time.Duration(i)*time.Minute)) {
+			t.Fatalf("unexpected sequence %#v, expected %d", seq, i)
+		}
+		if _, err := os.Stat(seq.Filename); err != nil {
+			t.Fatal(err)
+		}
+		if seq.Latest != (i == 20) {
+			t.Errorf("unexpected Latest for sequence %d", i)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxInFlight < 2 || maxInFlight > 4 {
+		t.Error("unexpected number of parallel requests", maxInFlight)
+	}
+
+	// all files are requested once, missing files only within the
+	// prefetch window after the last sequence
+	total := 0
+	for i := 1; i <= 23; i++ {
+		for _, ext := range []string{".state.txt", ".osc.gz"} {
+			path := fmt.Sprintf("/000/000/%03d%s", i, ext)
+			n := requests[path]
+			delete(requests, path)
+			if i <= 20 {
+				if n != 1 {
+					t.Errorf("%s requested %d times", path, n)
+				}
+				total += n
+			}
+		}
+	}
+	if total != 40 || len(requests) != 0 {
+		t.Errorf("unexpected number of requests %d, other requests %v", total, requests)
+	}
+}
@@ -1,2 +1,3 @@
-// Package diff provides functions for downloading OSM diff files.
+// Package diff provides functions for downloading and publishing OSM diff
+// files.
 package diff
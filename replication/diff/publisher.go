@@ -0,0 +1,109 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/omniscale/go-osm"
+	diffparser "github.com/omniscale/go-osm/parser/diff"
+	"github.com/omniscale/go-osm/replication"
+	"github.com/omniscale/go-osm/replication/internal/source"
+	"github.com/omniscale/go-osm/state"
+)
+
+// Publisher writes OSM diff files (.osc.gz) and their state files into a
+// replication directory with the same AAA/BBB/CCC layout as
+// planet.openstreetmap.org. The directory can be served with any HTTP file
+// server and consumed with NewDownloader or NewReader.
+//
+// A Publisher is not safe for concurrent use.
+type Publisher struct {
+	dir string
+	url string
+	seq int
+}
+
+// NewPublisher returns a publisher for the replication directory dir. url
+// is written as replicationUrl into the state files and can be empty.
+// Publishing continues after the sequence of an existing state.txt in dir,
+// or starts with sequence 1.
+func NewPublisher(dir, url string) (*Publisher, error) {
+	p := &Publisher{dir: dir, url: url}
+	s, err := state.ParseFile(filepath.Join(dir, "state.txt"))
+	if err == nil {
+		p.seq = s.Sequence
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading current state: %w", err)
+	}
+	return p, nil
+}
+
+// Sequence returns the last published sequence. Zero if nothing was
+// published yet.
+func (p *Publisher) Sequence() int {
+	return p.seq
+}
+
+// Publish writes diffs as the next replication sequence. t is the time of
+// the replication state, the diff should only contain changes older than
+// t. The .osc.gz file is written before the .state.txt file and the
+// top-level state.txt is updated last. All files are replaced atomically,
+// so that consumers never see incomplete files.
+func (p *Publisher) Publish(diffs []osm.Diff, t time.Time) (replication.Sequence, error) {
+	seq := p.seq + 1
+	base := filepath.Join(p.dir, filepath.FromSlash(source.SeqPath(seq)))
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return replication.Sequence{}, fmt.Errorf("creating replication dir: %w", err)
+	}
+
+	if err := writeDiffFile(base+".osc.gz", diffs); err != nil {
+		return replication.Sequence{}, err
+	}
+
+	// state files only store full seconds
+	s := &state.DiffState{Time: t.UTC().Truncate(time.Second), Sequence: seq, URL: p.url}
+	if err := state.WriteFile(base+".state.txt", s); err != nil {
+		return replication.Sequence{}, err
+	}
+	if err := state.WriteFile(filepath.Join(p.dir, "state.txt"), s); err != nil {
+		return replication.Sequence{}, err
+	}
+	p.seq = seq
+
+	return replication.Sequence{
+		Sequence:      seq,
+		Filename:      base + ".osc.gz",
+		StateFilename: base + ".state.txt",
+		Time:          s.Time,
+		Latest:        true,
+	}, nil
+}
+
+// writeDiffFile writes diffs as GZIP compressed .osc to a temporary file
+// and renames it to filename.
+func writeDiffFile(filename string, diffs []osm.Diff) error {
+	tmpname := filename + "~"
+	f, err := os.Create(tmpname)
+	if err != nil {
+		return fmt.Errorf("creating temp file for writing diff: %w", err)
+	}
+	w := diffparser.NewGZIPWriter(f, diffparser.WriterConfig{})
+	for _, d := range diffs {
+		if err = w.Write(d); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpname)
+		return fmt.Errorf("writing diff to %q: %w", tmpname, err)
+	}
+	return os.Rename(tmpname, filename)
+}
@@ -0,0 +1,104 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm"
+	diffparser "github.com/omniscale/go-osm/parser/diff"
+)
+
+func TestPublisher(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewPublisher(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	for i := 1; i <= 3; i++ {
+		diffs := []osm.Diff{
+			{Create: true, Node: &osm.Node{Element: osm.Element{ID: int64(i)}, Lat: 53, Long: 8}},
+			{Delete: true, Way: &osm.Way{Element: osm.Element{ID: int64(i)}}},
+		}
+		seq, err := p.Publish(diffs, start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq.Sequence != i || seq.Filename != filepath.Join(dir, fmt.Sprintf("000/000/%03d.osc.gz", i)) {
+			t.Errorf("unexpected sequence %#v", seq)
+		}
+	}
+
+	// publishing continues after existing state
+	p, err = NewPublisher(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Sequence() != 3 {
+		t.Error("unexpected sequence", p.Sequence())
+	}
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	current, err := CurrentSequence(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != 3 {
+		t.Error("unexpected current sequence", current)
+	}
+
+	dl := NewDownloader(t.TempDir(), srv.URL+"/", 1, time.Minute)
+	defer dl.Stop()
+	for i := 1; i <= 3; i++ {
+		seq := <-dl.Sequences()
+		if seq.Error != nil {
+			t.Fatal(seq.Error)
+		}
+		if seq.Sequence != i || !seq.Time.Equal(start.Add(time.Duration(i)*time.Minute)) {
+			t.Errorf("unexpected sequence %#v", seq)
+		}
+		if seq.Latest != (i == 3) {
+			t.Errorf("unexpected Latest for sequence %#v", seq)
+		}
+
+		diffs := parseDiffFile(t, seq.Filename)
+		if len(diffs) != 2 || !diffs[0].Create || diffs[0].Node.ID != int64(i) ||
+			!diffs[1].Delete || diffs[1].Way.ID != int64(i) {
+			t.Errorf("unexpected diffs %#v", diffs)
+		}
+	}
+}
+
+func parseDiffFile(t *testing.T, filename string) []osm.Diff {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	conf := diffparser.Config{Diffs: make(chan osm.Diff)}
+	p, err := diffparser.NewGZIP(f, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Diffs is closed before Parse returns, use the returned error
+	errc := make(chan error, 1)
+	go func() { errc <- p.Parse(context.Background()) }()
+	diffs := []osm.Diff{}
+	for d := range conf.Diffs {
+		diffs = append(diffs, d)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	return diffs
+}
@@ -0,0 +1,59 @@
+package source
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff calculates exponentially increasing delays with jitter for
+// failed attempts.
+type backoff struct {
+	min, max time.Duration
+	// attempts is the number of failed attempts since the last reset
+	attempts int
+	// rand returns a random number in [0.0, 1.0)
+	rand func() float64
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	if min <= 0 {
+		min = 60 * time.Second
+	}
+	if max <= 0 {
+		max = 5 * time.Minute
+	}
+	if max < min {
+		max = min
+	}
+	return &backoff{min: min, max: max, rand: rand.Float64}
+}
+
+// next records a failed attempt and returns the delay before the next
+// attempt. The delay is at least retryAfter.
+func (b *backoff) next(retryAfter time.Duration) time.Duration {
+	b.attempts++
+	delay := b.max
+	// prevent overflow for large number of attempts
+	if b.attempts < 32 {
+		if d := b.min << (b.attempts - 1); d > 0 && d < b.max {
+			delay = d
+		}
+	}
+	// subtract up to half of the delay as jitter
+	delay -= time.Duration(b.rand() * float64(delay/2))
+	if delay < retryAfter {
+		delay = retryAfter
+	}
+	return delay
+}
+
+// report returns true if the error of the current attempt should be
+// reported. This is true for each doubling of the attempts, to limit the
+// number of errors during longer outages.
+func (b *backoff) report() bool {
+	return b.attempts&(b.attempts-1) == 0
+}
+
+func (b *backoff) reset() {
+	b.attempts = 0
+}
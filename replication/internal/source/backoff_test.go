@@ -0,0 +1,177 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(time.Second, 10*time.Second)
+	b.rand = func() float64 { return 0 }
+	for i, want := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		if got := b.next(0); got != want*time.Second {
+			t.Errorf("attempt %d: got %s, want %s", i+1, got, want*time.Second)
+		}
+	}
+	// larger Retry-After takes precedence
+	if got := b.next(time.Minute); got != time.Minute {
+		t.Error("expected Retry-After delay, got", got)
+	}
+
+	// jitter subtracts up to half of the delay
+	b.reset()
+	b.rand = func() float64 { return 0.99 }
+	for i := 0; i < 40; i++ {
+		got := b.next(0)
+		max := time.Second << i
+		if i >= 4 {
+			max = 10 * time.Second
+		}
+		if got > max || got < max/2 {
+			t.Errorf("attempt %d: delay %s not within jitter range of %s", i+1, got, max)
+		}
+	}
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	b := newBackoff(0, 0)
+	if b.min != 60*time.Second || b.max != 5*time.Minute {
+		t.Errorf("unexpected defaults %s, %s", b.min, b.max)
+	}
+}
+
+func TestBackoffReport(t *testing.T) {
+	b := newBackoff(time.Second, time.Minute)
+	var reported []int
+	for i := 0; i < 20; i++ {
+		b.next(0)
+		if b.report() {
+			reported = append(reported, b.attempts)
+		}
+	}
+	want := []int{1, 2, 4, 8, 16}
+	if len(reported) != len(want) {
+		t.Fatal("unexpected reports", reported)
+	}
+	for i := range want {
+		if reported[i] != want[i] {
+			t.Fatal("unexpected reports", reported)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		status int
+		header string
+		want   time.Duration
+	}{
+		{http.StatusServiceUnavailable, "120", 2 * time.Minute},
+		{http.StatusTooManyRequests, "Thu, 02 Jan 2020 12:00:30 GMT", 30 * time.Second},
+		{http.StatusTooManyRequests, "Thu, 02 Jan 2020 11:00:00 GMT", 0},
+		{http.StatusTooManyRequests, "-1", 0},
+		{http.StatusTooManyRequests, "soon", 0},
+		{http.StatusInternalServerError, "120", 0},
+	} {
+		resp := &http.Response{StatusCode: tc.status, Header: http.Header{"Retry-After": []string{tc.header}}}
+		if got := retryAfter(resp, now); got != tc.want {
+			t.Errorf("%d %q: got %s, want %s", tc.status, tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestDownloaderMaxRetries(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	dl := NewDownloader(t.TempDir(), srv.URL+"/", 1, time.Minute, replication.DownloaderConfig{
+		RetryDelay: time.Millisecond,
+		MaxRetries: 3,
+	})
+	dl.StateExt = ".state.txt"
+	dl.FileExt = ".osc.gz"
+	dl.StateTime = func(string) (time.Time, error) { return time.Time{}, errors.New("no state") }
+	go dl.Start()
+	defer dl.Stop()
+
+	var errs []error
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case seq, ok := <-dl.Sequences():
+			if !ok {
+				done = true
+				break
+			}
+			if seq.Error == nil || seq.Sequence != 1 {
+				t.Fatalf("unexpected sequence %#v", seq)
+			}
+			errs = append(errs, seq.Error)
+		case <-timeout:
+			t.Fatal("timeout waiting for closed sequences")
+		}
+	}
+
+	// reports for the first and second attempt, and the final error
+	if len(errs) != 3 {
+		t.Fatal("unexpected errors", errs)
+	}
+	var statusErr *StatusError
+	if !errors.As(errs[0], &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Error("expected StatusError, got", errs[0])
+	}
+	if !errors.Is(errs[2], replication.ErrRetriesExhausted) || !errors.As(errs[2], &statusErr) {
+		t.Error("expected ErrRetriesExhausted with StatusError, got", errs[2])
+	}
+	if requests != 3 {
+		t.Error("unexpected number of requests", requests)
+	}
+}
+
+func TestDownloaderRetryAfter(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	dl := NewDownloader(t.TempDir(), srv.URL+"/", 1, time.Minute, replication.DownloaderConfig{
+		RetryDelay: time.Millisecond,
+		MaxRetries: 2,
+	})
+	dl.StateExt = ".state.txt"
+	start := time.Now()
+	_, err := dl.downloadTillSuccess(context.Background(), 1, dl.StateExt)
+	if !errors.Is(err, replication.ErrRetriesExhausted) || !strings.Contains(err.Error(), "503") {
+		t.Error("expected ErrRetriesExhausted, got", err)
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Error("Retry-After was not respected", d)
+	}
+	if requests != 2 {
+		t.Error("unexpected number of requests", requests)
+	}
+	// first failed attempt is reported
+	select {
+	case seq := <-dl.Sequences():
+		if seq.Error == nil {
+			t.Error("expected error sequence", seq)
+		}
+	default:
+		t.Error("expected reported error")
+	}
+}
@@ -0,0 +1,181 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+)
+
+const defaultUserAgent = "github.com/omniscale/go-osm"
+
+func defaultHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			Dial: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 1 * time.Second, // do not keep alive till next interval
+			}).Dial,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
+// Client requests files from a replication server and its mirrors.
+type Client struct {
+	http      *http.Client
+	userAgent string
+	header    http.Header
+	timeout   time.Duration
+	// baseURLs contains the replication URL, followed by the mirrors
+	baseURLs []string
+}
+
+// NewClient returns a client for the replication URL with the HTTP options
+// from conf.
+func NewClient(url string, conf replication.DownloaderConfig) *Client {
+	c := &Client{
+		http:      conf.Client,
+		userAgent: conf.UserAgent,
+		header:    conf.Header,
+		timeout:   conf.Timeout,
+		baseURLs:  append([]string{url}, conf.Mirrors...),
+	}
+	if c.http == nil {
+		c.http = defaultHTTPClient()
+	}
+	if c.userAgent == "" {
+		c.userAgent = defaultUserAgent
+	}
+	return c
+}
+
+// Fetch requests path from the replication URL and calls fn with the body
+// of the response. The mirrors are tried in order if the request or fn
+// fails, so fn needs to discard the results of previous calls. Returns
+// NotAvailable if the file is missing on all servers. Otherwise, the error
+// of the first failed server is returned, or the StatusError with the
+// longest RetryAfter.
+func (c *Client) Fetch(ctx context.Context, path string, fn func(io.Reader) error) error {
+	var result error
+	var resultRetryAfter time.Duration
+	for _, baseURL := range c.baseURLs {
+		err := c.fetch(ctx, baseURL+path, fn)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		debug("[debug] Fetching ", baseURL+path, ": ", err)
+		var na *NotAvailable
+		if errors.As(err, &na) {
+			continue
+		}
+		var se *StatusError
+		if result == nil {
+			result = err
+			if errors.As(err, &se) {
+				resultRetryAfter = se.RetryAfter
+			}
+		} else if errors.As(err, &se) && se.RetryAfter > resultRetryAfter {
+			result, resultRetryAfter = err, se.RetryAfter
+		}
+	}
+	if result == nil {
+		return &NotAvailable{c.baseURLs[0] + path}
+	}
+	return result
+}
+
+// Get returns the body of path, see Fetch.
+func (c *Client) Get(ctx context.Context, path string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := c.Fetch(ctx, path, func(r io.Reader) error {
+		buf.Reset()
+		_, err := io.Copy(buf, r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *Client) fetch(ctx context.Context, url string, fn func(io.Reader) error) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return &NotAvailable{url}
+	}
+	if resp.StatusCode != 200 {
+		return &StatusError{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			RetryAfter: retryAfter(resp, time.Now()),
+		}
+	}
+	return fn(resp.Body)
+}
+
+// StatusError is returned for unexpected HTTP responses.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is the requested delay from the Retry-After header of
+	// 429 and 503 responses.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("invalid response for %s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// retryAfter returns the delay from the Retry-After header (seconds or
+// HTTP date) of 429 and 503 responses.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
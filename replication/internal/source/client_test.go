@@ -0,0 +1,124 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+)
+
+func TestClientOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "test/1.0" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL+"/", replication.DownloaderConfig{
+		UserAgent: "test/1.0",
+		Header:    http.Header{"Authorization": []string{"Bearer secret"}},
+	})
+	b, err := c.Get(context.Background(), "state.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ok" {
+		t.Error("unexpected body", string(b))
+	}
+
+	// default user agent
+	if _, err := NewClient(srv.URL+"/", replication.DownloaderConfig{}).Get(context.Background(), "state.txt"); err == nil {
+		t.Error("expected error without user agent and header")
+	}
+}
+
+func TestClientMirrors(t *testing.T) {
+	var requests []string
+	handler := func(name string, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, name+r.URL.Path)
+			w.WriteHeader(status)
+			w.Write([]byte(name))
+		}))
+	}
+	failing := handler("failing", http.StatusInternalServerError)
+	defer failing.Close()
+	missing := handler("missing", http.StatusNotFound)
+	defer missing.Close()
+	mirror := handler("mirror", http.StatusOK)
+	defer mirror.Close()
+
+	c := NewClient(failing.URL+"/", replication.DownloaderConfig{
+		Mirrors: []string{missing.URL + "/", mirror.URL + "/"},
+	})
+	b, err := c.Get(context.Background(), "000/000/001.state.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "mirror" {
+		t.Error("unexpected body", string(b))
+	}
+	want := []string{"failing/000/000/001.state.txt", "missing/000/000/001.state.txt", "mirror/000/000/001.state.txt"}
+	if len(requests) != 3 || requests[0] != want[0] || requests[1] != want[1] || requests[2] != want[2] {
+		t.Error("unexpected requests", requests)
+	}
+
+	// NotAvailable only if missing on all servers
+	c = NewClient(missing.URL+"/", replication.DownloaderConfig{Mirrors: []string{missing.URL + "/"}})
+	_, err = c.Get(context.Background(), "state.txt")
+	var na *NotAvailable
+	if !errors.As(err, &na) {
+		t.Error("expected NotAvailable, got", err)
+	}
+	c = NewClient(missing.URL+"/", replication.DownloaderConfig{Mirrors: []string{failing.URL + "/"}})
+	_, err = c.Get(context.Background(), "state.txt")
+	if err == nil || errors.As(err, &na) {
+		t.Error("expected error from failing mirror, got", err)
+	}
+
+	// error of the replication URL, unless a mirror requests a longer delay
+	unavailable := handler("unavailable", http.StatusServiceUnavailable)
+	defer unavailable.Close()
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+	var se *StatusError
+	c = NewClient(failing.URL+"/", replication.DownloaderConfig{Mirrors: []string{missing.URL + "/", unavailable.URL + "/"}})
+	_, err = c.Get(context.Background(), "state.txt")
+	if !errors.As(err, &se) || se.URL != failing.URL+"/state.txt" {
+		t.Error("expected StatusError from replication URL, got", err)
+	}
+	c = NewClient(failing.URL+"/", replication.DownloaderConfig{Mirrors: []string{limited.URL + "/", unavailable.URL + "/"}})
+	_, err = c.Get(context.Background(), "state.txt")
+	if !errors.As(err, &se) || se.URL != limited.URL+"/state.txt" || se.RetryAfter != 120*time.Second {
+		t.Error("expected StatusError with Retry-After from mirror, got", err)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	c := NewClient(srv.URL+"/", replication.DownloaderConfig{Timeout: 50 * time.Millisecond})
+	if _, err := c.Get(context.Background(), "state.txt"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected timeout, got", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Error("request took too long", d)
+	}
+}
@@ -0,0 +1,110 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ParseState parses the content of a state file and returns the sequence
+// and the time. The sequence can be zero for the state files of a single
+// sequence.
+type ParseState func([]byte) (int, time.Time, error)
+
+// SequenceForTime returns the last sequence with a time before t. It
+// fetches the current state file, and then the state files of older
+// sequences (AAA/BBB/CCC + stateExt) till it finds the sequence with a
+// binary search.
+func SequenceForTime(client *Client, currentState, stateExt string, parse ParseState, t time.Time) (int, error) {
+	ctx := context.Background()
+	b, err := client.Get(ctx, currentState)
+	if err != nil {
+		return 0, fmt.Errorf("fetching current state: %w", err)
+	}
+	current, currentTime, err := parse(b)
+	if err != nil {
+		return 0, fmt.Errorf("parsing current state: %w", err)
+	}
+	if currentTime.Before(t) {
+		return current, nil
+	}
+
+	seqTime := func(seq int) (time.Time, error) {
+		b, err := client.Get(ctx, SeqPath(seq)+stateExt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("fetching state of sequence %d: %w", seq, err)
+		}
+		_, seqTime, err := parse(b)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parsing state of sequence %d: %w", seq, err)
+		}
+		return seqTime, nil
+	}
+
+	// search backwards with increasing steps for a sequence before t, most
+	// requested times are close to the current time
+	hi := current // always at or after t
+	lo := current
+	for step := 1; ; step *= 2 {
+		lo = hi - step
+		if lo < 0 {
+			lo = 0
+		}
+		loTime, err := seqTime(lo)
+		var na *NotAvailable
+		if errors.As(err, &na) {
+			// old sequences can be removed from the server, continue with
+			// the first available sequence
+			lo, loTime, err = firstAvailable(lo, hi, seqTime)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if loTime.Before(t) {
+			break
+		}
+		if lo == 0 || lo == hi {
+			return 0, fmt.Errorf("no sequence before %s, first sequence is from %s", t, loTime)
+		}
+		hi = lo
+	}
+
+	// lo is before t, hi is at or after t
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		midTime, err := seqTime(mid)
+		if err != nil {
+			return 0, err
+		}
+		if midTime.Before(t) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// firstAvailable returns the first available sequence after missing, up
+// to hi.
+func firstAvailable(missing, hi int, seqTime func(int) (time.Time, error)) (int, time.Time, error) {
+	hiTime, err := seqTime(hi)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	for hi-missing > 1 {
+		mid := missing + (hi-missing)/2
+		midTime, err := seqTime(mid)
+		var na *NotAvailable
+		if errors.As(err, &na) {
+			missing = mid
+			continue
+		}
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		hi, hiTime = mid, midTime
+	}
+	return hi, hiTime, nil
+}
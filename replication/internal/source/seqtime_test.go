@@ -0,0 +1,83 @@
+package source
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+)
+
+func TestSequenceForTime(t *testing.T) {
+	// synthetic replication tree with sequences from first to 123456, one
+	// sequence per minute
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	current := 123456
+	first := 1000
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		seq := current
+		if r.URL.Path != "/state" {
+			p := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".state")
+			n, err := strconv.Atoi(strings.ReplaceAll(p, "/", ""))
+			if err != nil || SeqPath(n) != p || n < first || n > current {
+				http.NotFound(w, r)
+				return
+			}
+			seq = n
+		}
+		fmt.Fprintf(w, "%d %d", seq, base.Add(time.Duration(seq)*time.Minute).Unix())
+	}))
+	defer srv.Close()
+
+	parse := func(b []byte) (int, time.Time, error) {
+		var seq int
+		var ts int64
+		_, err := fmt.Sscanf(string(b), "%d %d", &seq, &ts)
+		return seq, time.Unix(ts, 0), err
+	}
+	seqTime := func(seq int) time.Time {
+		return base.Add(time.Duration(seq) * time.Minute)
+	}
+
+	for _, tc := range []struct {
+		t       time.Time
+		want    int
+		wantErr bool
+	}{
+		{t: seqTime(current).Add(time.Second), want: current},
+		{t: seqTime(current), want: current - 1},
+		{t: seqTime(current - 1).Add(time.Second), want: current - 1},
+		{t: seqTime(100000), want: 99999},
+		{t: seqTime(100000).Add(30 * time.Second), want: 100000},
+		{t: seqTime(first + 1), want: first},
+		{t: seqTime(first), wantErr: true},
+		{t: base, wantErr: true},
+	} {
+		t.Run(tc.t.String(), func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			seq, err := SequenceForTime(NewClient(srv.URL+"/", replication.DownloaderConfig{}), "state", ".state", parse, tc.t)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %d", seq)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seq != tc.want {
+				t.Errorf("got %d, want %d", seq, tc.want)
+			}
+			if n := atomic.LoadInt32(&requests); n > 60 {
+				t.Errorf("too many requests: %d", n)
+			}
+		})
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net"
-	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -16,6 +14,7 @@ import (
 	"gopkg.in/fsnotify.v1"
 
 	"github.com/omniscale/go-osm/replication"
+	"github.com/omniscale/go-osm/state"
 )
 
 var isDebug = false
@@ -34,8 +33,9 @@ func (e *NotAvailable) Error() string {
 	return fmt.Sprintf("File not available: %s", e.url)
 }
 
+// SeqPath returns the AAA/BBB/CCC path of a replication file, with
 // N = AAA*1000000 + BBB*1000 + CCC
-func seqPath(seq int) string {
+func SeqPath(seq int) string {
 	c := seq % 1000
 	b := seq / 1000 % 1000
 	a := seq / 1000000
@@ -43,37 +43,40 @@ func seqPath(seq int) string {
 	return fmt.Sprintf("%03d/%03d/%03d", a, b, c)
 }
 
-var _ replication.Source = &downloader{}
+var (
+	_ replication.Source = &downloader{}
+	_ replication.Acker  = &downloader{}
+)
 
 type downloader struct {
-	baseUrl      string
-	dest         string
-	FileExt      string
-	StateExt     string
+	baseUrl  string
+	dest     string
+	FileExt  string
+	StateExt string
+	// StateFile stores the last acknowledged sequence, optional.
+	StateFile string
+	// ParseState and FileRoot are used to verify downloaded and existing
+	// files, optional. FileRoot is the expected XML root element.
+	ParseState   ParseState
+	FileRoot     string
 	lastSequence int
 	StateTime    func(string) (time.Time, error)
 	interval     time.Duration
-	errWaittime  time.Duration
-	naWaittime   time.Duration
-	sequences    chan replication.Sequence
-	client       *http.Client
-	ctx          context.Context
-	cancel       context.CancelFunc
+	backoff      *backoff
+	maxRetries   int
+	concurrency  int
+	// prefetches contains a channel for each sequence that is downloaded
+	// in the background, closed once the download finished
+	prefetches map[int]chan struct{}
+	naWaittime time.Duration
+	sequences  chan replication.Sequence
+	client     *Client
+	ctx        context.Context
+	cancel     context.CancelFunc
 }
 
-func NewDownloader(dest, url string, seq int, interval time.Duration) *downloader {
-	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			Dial: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 1 * time.Second, // do not keep alive till next interval
-			}).Dial,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ResponseHeaderTimeout: 10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		},
-	}
+func NewDownloader(dest, url string, seq int, interval time.Duration, conf replication.DownloaderConfig) *downloader {
+	client := NewClient(url, conf)
 
 	var naWaittime time.Duration
 	switch {
@@ -85,16 +88,25 @@ func NewDownloader(dest, url string, seq int, interval time.Duration) *downloade
 		naWaittime = 10 * time.Second
 	}
 
+	concurrency := conf.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	dl := &downloader{
 		baseUrl:      url,
 		dest:         dest,
 		lastSequence: seq - 1, // we want to start with seq, so lastSequence is -1
 		interval:     interval,
-		errWaittime:  60 * time.Second,
+		backoff:      newBackoff(conf.RetryDelay, conf.MaxRetryDelay),
+		maxRetries:   conf.MaxRetries,
+		concurrency:  concurrency,
+		prefetches:   make(map[int]chan struct{}),
 		naWaittime:   naWaittime,
 		sequences:    make(chan replication.Sequence, 4),
 		client:       client,
+		StateFile:    conf.StateFile,
 		ctx:          ctx,
 		cancel:       cancel,
 	}
@@ -106,51 +118,81 @@ func (d *downloader) Sequences() <-chan replication.Sequence {
 	return d.sequences
 }
 
-func (d *downloader) download(seq int, ext string) error {
-	dest := path.Join(d.dest, seqPath(seq)+ext)
-	url := d.baseUrl + seqPath(seq) + ext
-	debug("[debug] Downloading diff file from ", url)
-
-	if _, err := os.Stat(dest); err == nil {
+// Resume continues with the sequence after the one stored in StateFile.
+// Keeps the initial sequence if StateFile is not set or does not exist.
+// Needs to be called before Start.
+func (d *downloader) Resume() error {
+	if d.StateFile == "" {
 		return nil
 	}
-
-	if err := os.MkdirAll(path.Dir(dest), 0755); err != nil {
-		return err
+	s, err := state.ParseFile(d.StateFile)
+	if os.IsNotExist(err) {
+		return nil
 	}
-
-	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return err
+		return fmt.Errorf("reading state file %q: %w", d.StateFile, err)
 	}
-	req.Header.Set("User-Agent", "github.com/omniscale/go-osm")
-	resp, err := d.client.Do(req)
+	d.lastSequence = s.Sequence
+	return nil
+}
+
+// Ack writes seq and the time of seq into StateFile, if StateFile is set.
+func (d *downloader) Ack(seq int) error {
+	if d.StateFile == "" {
+		return nil
+	}
+	stateFile := path.Join(d.dest, SeqPath(seq)+d.StateExt)
+	t, err := d.StateTime(stateFile)
 	if err != nil {
-		return err
+		return fmt.Errorf("reading time of sequence %d: %w", seq, err)
 	}
+	return state.WriteFile(d.StateFile, &state.DiffState{Time: t, Sequence: seq, URL: d.baseUrl})
+}
 
-	defer resp.Body.Close()
+func (d *downloader) download(seq int, ext string) error {
+	dest := path.Join(d.dest, SeqPath(seq)+ext)
+	debug("[debug] Downloading diff file from ", d.baseUrl+SeqPath(seq)+ext)
 
-	if resp.StatusCode == 404 {
-		return &NotAvailable{url}
+	if _, err := os.Stat(dest); err == nil {
+		// file from previous run, might be incomplete
+		err := d.verify(dest, seq, ext)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, replication.ErrInvalidFile) {
+			return err
+		}
+		debug("[warn] Removing invalid file", dest, err)
+		if err := os.Remove(dest); err != nil {
+			return err
+		}
 	}
 
-	if resp.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("invalid response: %v", resp))
+	if err := os.MkdirAll(path.Dir(dest), 0755); err != nil {
+		return err
 	}
 
 	tmpDest := fmt.Sprintf("%s~%d", dest, os.Getpid())
-	out, err := os.Create(tmpDest)
-	if err != nil {
-		return err
+	err := d.client.Fetch(d.ctx, SeqPath(seq)+ext, func(r io.Reader) error {
+		out, err := os.Create(tmpDest)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		if _, err := io.Copy(out, r); err != nil {
+			return err
+		}
+		return out.Close()
+	})
+	if err == nil {
+		if err = d.verify(tmpDest, seq, ext); err != nil {
+			err = fmt.Errorf("verifying %s: %w", SeqPath(seq)+ext, err)
+		}
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
 	if err != nil {
+		os.Remove(tmpDest)
 		return err
 	}
-	out.Close()
 
 	err = os.Rename(tmpDest, dest)
 	if err != nil {
@@ -161,26 +203,50 @@ func (d *downloader) download(seq int, ext string) error {
 }
 
 // downloadTillSuccess tries to download file till it is available, returns
-// true if available on first try.
-func (d *downloader) downloadTillSuccess(ctx context.Context, seq int, ext string) bool {
+// true if available on first try. Failed attempts are retried with
+// increasing delays. Returns an error if all retries failed.
+func (d *downloader) downloadTillSuccess(ctx context.Context, seq int, ext string) (bool, error) {
+	d.backoff.reset()
+	failed := 0
 	for tries := 0; ; tries++ {
 		if ctx.Err() != nil {
-			return false
+			return false, nil
 		}
 		err := d.download(seq, ext)
 		if err == nil {
-			return tries == 0
+			return tries == 0, nil
+		}
+		if ctx.Err() != nil {
+			// request was canceled by Stop
+			return false, nil
 		}
 		if _, ok := err.(*NotAvailable); ok {
 			wait(ctx, d.naWaittime)
-		} else {
-			debug("[error] Downloading file:", err)
+			continue
+		}
+
+		debug("[error] Downloading file:", err)
+		failed++
+		var retryAfter time.Duration
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			retryAfter = statusErr.RetryAfter
+		}
+		delay := d.backoff.next(retryAfter)
+		if d.maxRetries > 0 && d.backoff.attempts >= d.maxRetries {
+			return false, fmt.Errorf("%w after %d attempts: %w", replication.ErrRetriesExhausted, d.backoff.attempts, err)
+		}
+		if d.backoff.report() {
+			if failed > 1 {
+				err = fmt.Errorf("%d failed attempts, last error: %w", failed, err)
+			}
 			d.sequences <- replication.Sequence{
 				Sequence: seq,
 				Error:    err,
 			}
-			wait(ctx, d.errWaittime)
+			failed = 0
 		}
+		wait(ctx, delay)
 	}
 }
 
@@ -199,9 +265,46 @@ func (d *downloader) Stop() {
 	d.cancel()
 }
 
+// prefetch starts background downloads for the state and data files of
+// the sequences after nextSeq, so that up to concurrency sequences are
+// downloaded in parallel. nextSeq itself is downloaded by the caller.
+// Failed downloads are ignored, they are retried by downloadTillSuccess.
+func (d *downloader) prefetch(nextSeq int) {
+	for seq := nextSeq + 1; seq < nextSeq+d.concurrency; seq++ {
+		if _, ok := d.prefetches[seq]; ok {
+			continue
+		}
+		done := make(chan struct{})
+		d.prefetches[seq] = done
+		go func(seq int) {
+			defer close(done)
+			if err := d.download(seq, d.StateExt); err != nil {
+				return
+			}
+			d.download(seq, d.FileExt)
+		}(seq)
+	}
+}
+
+// waitPrefetch waits till the background download of seq finished, so
+// that the files of seq are not downloaded twice at the same time.
+func (d *downloader) waitPrefetch(seq int) {
+	done, ok := d.prefetches[seq]
+	if !ok {
+		return
+	}
+	select {
+	case <-done:
+	case <-d.ctx.Done():
+	}
+	delete(d.prefetches, seq)
+}
+
 func (d *downloader) fetchNextLoop() {
-	stateFile := path.Join(d.dest, seqPath(d.lastSequence)+d.StateExt)
+	stateFile := path.Join(d.dest, SeqPath(d.lastSequence)+d.StateExt)
 	lastTime, err := d.StateTime(stateFile)
+	// latest is true once the last sequence was reached
+	latest := false
 	for {
 		nextSeq := d.lastSequence + 1
 		debug("[debug] Processing download for sequence", nextSeq)
@@ -214,21 +317,38 @@ func (d *downloader) fetchNextLoop() {
 				waitFor := nextDiffTime.Sub(time.Now())
 				debug("[debug] Waiting for next download in", waitFor)
 				wait(d.ctx, waitFor)
+				latest = true
 			}
 		}
+		if d.concurrency > 1 && !latest {
+			d.prefetch(nextSeq)
+		}
+		d.waitPrefetch(nextSeq)
 		// download will retry until they succeed
-		d.downloadTillSuccess(d.ctx, nextSeq, d.StateExt)
-		noWait := d.downloadTillSuccess(d.ctx, nextSeq, d.FileExt)
+		_, dlErr := d.downloadTillSuccess(d.ctx, nextSeq, d.StateExt)
+		var noWait bool
+		if dlErr == nil {
+			noWait, dlErr = d.downloadTillSuccess(d.ctx, nextSeq, d.FileExt)
+		}
+		if dlErr != nil {
+			// retries exhausted, send final error
+			d.sequences <- replication.Sequence{
+				Sequence: nextSeq,
+				Error:    dlErr,
+			}
+			close(d.sequences)
+			return
+		}
 		if d.ctx.Err() != nil {
 			close(d.sequences)
 			return
 		}
 		d.lastSequence = nextSeq
-		base := path.Join(d.dest, seqPath(d.lastSequence))
+		base := path.Join(d.dest, SeqPath(d.lastSequence))
 		lastTime, _ = d.StateTime(base + d.StateExt)
 
-		var latest bool
 		if noWait {
+			d.waitPrefetch(nextSeq + 1)
 			if d.download(nextSeq+1, d.StateExt) == nil {
 				// next sequence is immediately available
 				latest = false
@@ -283,7 +403,7 @@ func (d *reader) Sequences() <-chan replication.Sequence {
 }
 
 func (d *reader) waitTillPresent(ctx context.Context, seq int, ext string) error {
-	filename := path.Join(d.dest, seqPath(seq)+ext)
+	filename := path.Join(d.dest, SeqPath(seq)+ext)
 	return waitTillPresent(ctx, filename)
 }
 
@@ -319,7 +439,7 @@ func (d *reader) fetchNextLoop() {
 			return
 		}
 		d.lastSequence = nextSeq
-		base := path.Join(d.dest, seqPath(d.lastSequence))
+		base := path.Join(d.dest, SeqPath(d.lastSequence))
 		lastTime, _ := d.StateTime(base + d.StateExt)
 
 		latest := !d.seqIsAvailable(d.lastSequence+1, d.StateExt)
@@ -334,7 +454,7 @@ func (d *reader) fetchNextLoop() {
 }
 
 func (d *reader) seqIsAvailable(seq int, ext string) bool {
-	filename := path.Join(d.dest, seqPath(seq)+ext)
+	filename := path.Join(d.dest, SeqPath(seq)+ext)
 	_, err := os.Stat(filename)
 	return err == nil
 }
@@ -0,0 +1,80 @@
+package source
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/omniscale/go-osm/replication"
+)
+
+// verify checks that filename is a valid replication file for seq. State
+// files (StateExt) need to parse with ParseState and need to contain seq.
+// Other files need to be a complete gzip stream with FileRoot as XML root
+// element. The returned error wraps replication.ErrInvalidFile if the
+// content is invalid.
+func (d *downloader) verify(filename string, seq int, ext string) error {
+	if ext == d.StateExt {
+		if d.ParseState == nil {
+			return nil
+		}
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		return verifyState(b, seq, d.ParseState)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return verifyGzipXML(f, d.FileRoot)
+}
+
+func verifyState(b []byte, seq int, parse ParseState) error {
+	stateSeq, t, err := parse(b)
+	if err != nil {
+		return fmt.Errorf("%w: parsing state: %w", replication.ErrInvalidFile, err)
+	}
+	if t.IsZero() {
+		return fmt.Errorf("%w: state without time", replication.ErrInvalidFile)
+	}
+	if stateSeq != 0 && stateSeq != seq {
+		return fmt.Errorf("%w: state for sequence %d, expected %d", replication.ErrInvalidFile, stateSeq, seq)
+	}
+	return nil
+}
+
+// verifyGzipXML checks that r is a complete gzip stream and that the XML
+// root element is root. The root element is not checked if root is empty.
+func verifyGzipXML(r io.Reader, root string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", replication.ErrInvalidFile, err)
+	}
+	defer gr.Close()
+
+	if root != "" {
+		dec := xml.NewDecoder(gr)
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return fmt.Errorf("%w: searching XML root element: %w", replication.ErrInvalidFile, err)
+			}
+			if start, ok := tok.(xml.StartElement); ok {
+				if start.Name.Local != root {
+					return fmt.Errorf("%w: XML root element is %q, expected %q", replication.ErrInvalidFile, start.Name.Local, root)
+				}
+				break
+			}
+		}
+	}
+	// gzip verifies the size and checksum at the end of the stream
+	if _, err := io.Copy(io.Discard, gr); err != nil {
+		return fmt.Errorf("%w: %w", replication.ErrInvalidFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package source
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+)
+
+func gzipData(t *testing.T, s string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const testDiff = `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6" generator="test">
+<create><node id="1" lat="53" lon="8"/></create>
+</osmChange>
+`
+
+func TestVerifyGzipXML(t *testing.T) {
+	valid := gzipData(t, testDiff)
+	for _, tc := range []struct {
+		name    string
+		data    []byte
+		root    string
+		invalid bool
+	}{
+		{"valid", valid, "osmChange", false},
+		{"without root check", valid, "", false},
+		{"other root", valid, "osm", true},
+		{"truncated", valid[:len(valid)-6], "osmChange", true},
+		{"truncated without root check", valid[:len(valid)-6], "", true},
+		{"html", []byte("<html><body>Not Found</body></html>"), "osmChange", true},
+		{"gzipped html", gzipData(t, "<html><body>Error</body></html>"), "osmChange", true},
+		{"no XML", gzipData(t, "foo"), "osmChange", true},
+		{"empty", nil, "osmChange", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyGzipXML(bytes.NewReader(tc.data), tc.root)
+			if tc.invalid && !errors.Is(err, replication.ErrInvalidFile) {
+				t.Error("expected ErrInvalidFile, got", err)
+			}
+			if !tc.invalid && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func parseTestState(b []byte) (int, time.Time, error) {
+	seq, ts, ok := strings.Cut(string(b), " ")
+	if !ok {
+		return 0, time.Time{}, errors.New("invalid state")
+	}
+	n, err := strconv.Atoi(seq)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	return n, t, err
+}
+
+func TestVerifyState(t *testing.T) {
+	if err := verifyState([]byte("42 2020-01-02T03:04:05Z"), 42, parseTestState); err != nil {
+		t.Error(err)
+	}
+	if err := verifyState([]byte("0 2020-01-02T03:04:05Z"), 42, parseTestState); err != nil {
+		t.Error("sequence is optional, got", err)
+	}
+	for _, state := range []string{"43 2020-01-02T03:04:05Z", "42 0001-01-01T00:00:00Z", "<html>"} {
+		if err := verifyState([]byte(state), 42, parseTestState); !errors.Is(err, replication.ErrInvalidFile) {
+			t.Errorf("expected ErrInvalidFile for %q, got %v", state, err)
+		}
+	}
+}
+
+func TestDownloaderVerify(t *testing.T) {
+	requests := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/000/000/001.state.txt":
+			w.Write([]byte("1 2020-01-02T03:04:05Z"))
+		case "/000/000/001.osc.gz":
+			if requests[r.URL.Path] == 1 {
+				// truncated on first request
+				w.Write(gzipData(t, testDiff)[:20])
+				return
+			}
+			w.Write(gzipData(t, testDiff))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dest := t.TempDir()
+	// invalid state from a previous run
+	stateFile := filepath.Join(dest, "000", "000", "001.state.txt")
+	if err := os.MkdirAll(filepath.Dir(stateFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stateFile, []byte("<html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dl := NewDownloader(dest, srv.URL+"/", 1, time.Minute, replication.DownloaderConfig{RetryDelay: time.Millisecond})
+	dl.StateExt = ".state.txt"
+	dl.FileExt = ".osc.gz"
+	dl.ParseState = parseTestState
+	dl.FileRoot = "osmChange"
+
+	ctx := context.Background()
+	if _, err := dl.downloadTillSuccess(ctx, 1, dl.StateExt); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(stateFile); string(b) != "1 2020-01-02T03:04:05Z" {
+		t.Errorf("invalid state file was not replaced: %q", b)
+	}
+	if _, err := dl.downloadTillSuccess(ctx, 1, dl.FileExt); err != nil {
+		t.Fatal(err)
+	}
+	if requests["/000/000/001.osc.gz"] != 2 {
+		t.Error("expected retry after truncated download, got requests", requests)
+	}
+	select {
+	case seq := <-dl.Sequences():
+		if !errors.Is(seq.Error, replication.ErrInvalidFile) {
+			t.Error("expected ErrInvalidFile, got", seq.Error)
+		}
+	default:
+		t.Error("expected reported error")
+	}
+
+	// valid files are not downloaded again
+	if err := dl.download(1, dl.FileExt); err != nil {
+		t.Fatal(err)
+	}
+	if requests["/000/000/001.osc.gz"] != 2 || requests["/000/000/001.state.txt"] != 1 {
+		t.Error("unexpected requests", requests)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dest, "000", "000", "*~*"))
+	if len(matches) != 0 {
+		t.Error("temporary files not removed", matches)
+	}
+}
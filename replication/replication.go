@@ -1,6 +1,10 @@
 package replication
 
-import "time"
+import (
+	"errors"
+	"net/http"
+	"time"
+)
 
 // A Sequence contains metadata for a replication file (diff or changeset).
 type Sequence struct {
@@ -8,6 +12,8 @@ type Sequence struct {
 	Sequence int
 	// Error describes any an error that occurred the during download of the
 	// replication file. The filenames and Time are zero if Error is set.
+	// Errors of repeated attempts are aggregated, they are sent at most
+	// once for every doubling of the retry delay.
 	Error error
 	// Filename specifies the full path to the replication file.
 	Filename string
@@ -29,3 +35,70 @@ type Source interface {
 	// files and that Sequences channel should be closed.
 	Stop()
 }
+
+// An Acker confirms processed replication files. The Sources returned by
+// the downloaders implement Acker.
+type Acker interface {
+	// Ack confirms that the replication file seq was successfully
+	// processed. Downloaders with a DownloaderConfig.StateFile store seq
+	// in this file, so that they can resume with the next sequence after
+	// a restart.
+	Ack(seq int) error
+}
+
+// DownloaderConfig contains optional settings for downloaders.
+type DownloaderConfig struct {
+	// StateFile is the path to a state file (state.txt format) that
+	// stores the last acknowledged sequence (see Acker). If the
+	// file exists, the downloader starts with the sequence after the one
+	// in this file, instead of the sequence passed to the downloader.
+	StateFile string
+
+	// Client is used for all HTTP requests. Defaults to a client with
+	// connection timeouts suitable for replication downloads. Use a
+	// client with a custom Transport for proxies or other
+	// http.RoundTripper.
+	Client *http.Client
+	// UserAgent is sent with all requests. Defaults to
+	// github.com/omniscale/go-osm. Please identify your application, as
+	// requested by the usage policy of planet.openstreetmap.org.
+	UserAgent string
+	// Header contains additional headers for all requests (e.g.
+	// Authorization).
+	Header http.Header
+	// Timeout limits the duration of each request, including reading the
+	// body. No limit if zero.
+	Timeout time.Duration
+	// Mirrors are fallback base URLs that are tried in order if a request
+	// to the replication URL or the previous mirror fails.
+	Mirrors []string
+
+	// RetryDelay is the delay before the first retry of a failed download.
+	// The delay doubles with each failed attempt, up to MaxRetryDelay. A
+	// random jitter of up to half of the delay is subtracted. A longer
+	// Retry-After from 429 and 503 responses is respected. Defaults to 60
+	// seconds and 5 minutes. Missing files of new sequences are not
+	// considered as failed.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// MaxRetries limits the number of failed attempts for a single file.
+	// The downloader stops after the last attempt and sends a final
+	// Sequence with an error that wraps ErrRetriesExhausted, before the
+	// Sequences channel is closed. Retries forever if zero.
+	MaxRetries int
+
+	// Concurrency is the number of sequences that are downloaded in
+	// parallel while the downloader is catching up. Sequences are still
+	// delivered in order. Only a single sequence is requested at a time
+	// once the latest sequence is reached. Defaults to 1.
+	Concurrency int
+}
+
+// ErrRetriesExhausted is returned with the final Sequence of a downloader
+// that reached DownloaderConfig.MaxRetries.
+var ErrRetriesExhausted = errors.New("replication download retries exhausted")
+
+// ErrInvalidFile is returned for downloaded replication files that are
+// incomplete or corrupt, e.g. truncated gzip streams, error pages or state
+// files of another sequence. Invalid files are removed and downloaded again.
+var ErrInvalidFile = errors.New("invalid replication file")